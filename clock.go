@@ -0,0 +1,66 @@
+package bowtie
+
+import (
+	"sync"
+	"time"
+)
+
+// Interface Clock provides the current time. Everything in Bowtie that measures time (like
+// Context.GetRunningTime(), and therefore the loggers) obtains it from DefaultClock, so that
+// tests can replace it with a FakeClock and get deterministic results. Request deadlines are
+// the exception: they are enforced by context.Context, which always uses the system time.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is a Clock that returns the system time
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// RealClock is a Clock that returns the system time
+var RealClock Clock = realClock{}
+
+// DefaultClock is the clock used throughout Bowtie. It should only be changed in tests, before
+// any request is served, and restored to RealClock afterwards
+var DefaultClock = RealClock
+
+// Struct FakeClock is a Clock whose time only changes when it is set or advanced explicitly.
+// It is safe for concurrent use.
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+var _ Clock = &FakeClock{}
+
+// NewFakeClock creates a new fake clock set to `now`
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time
+func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.now
+}
+
+// Set changes the clock's current time to `now`
+func (f *FakeClock) Set(now time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.now = now
+}
+
+// Advance moves the clock's current time forward by `d`
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.now = f.now.Add(d)
+}
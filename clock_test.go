@@ -0,0 +1,27 @@
+package bowtie
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFakeClockRunningTime(t *testing.T) {
+	clock := NewFakeClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	DefaultClock = clock
+	defer func() { DefaultClock = RealClock }()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	c := NewContext(r, newMockWriter())
+
+	if d := c.GetRunningTime(); d != 0 {
+		t.Errorf("Expected a running time of 0, got %s instead", d)
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+
+	if d := c.GetRunningTime(); d != 1500*time.Millisecond {
+		t.Errorf("Expected a running time of 1.5s, got %s instead", d)
+	}
+}
@@ -1,14 +1,22 @@
 package bowtie
 
 import (
+	"context"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // ContextFactory is a function that processes a context.
 // Your application (and each middleware) can provide its own factory when the server is created,
-// thus allowing you to set new values into the context as needed
+// thus allowing you to set new values into the context as needed.
+//
+// A factory that fails (for example, because it cannot open a per-request resource) can signal
+// the failure by adding an error to the context's response writer; in that case, the server
+// responds with the error's status code (500 for plain Go errors) and a JSON body that lists
+// the errors, formatted as the ErrorReporter middleware would, without running any middleware.
+// Observers registered with Server.OnResponse still run, so the failure can be logged there.
 type ContextFactory func(context Context)
 
 type ContextKey int64
@@ -35,30 +43,127 @@ type Context interface {
 	// Response returns the response writer associated with this request
 	Response() ResponseWriter
 
+	// Respond sets the response's status to `status`, serializes `body` to JSON (unless it is nil)
+	// and writes it to the output stream. Because the response is marked as written, no further
+	// middleware runs after the current one
+	Respond(status int, body interface{})
+
+	// ServeConditional handles conditional GET and HEAD requests. If the request's If-None-Match
+	// or If-Modified-Since headers show that the client already has the current version of the
+	// resource, identified by `etag` and `modTime`, a 304 Not Modified status is written.
+	// Otherwise, the ETag and Last-Modified headers are set and `write` is called to output the
+	// resource. Either `etag` or `modTime` can be left empty.
+	//
+	// If the Content-Encoding header has already been set (for example, because the resource is
+	// served precompressed), the encoding is appended to the ETag, so that each representation
+	// has its own tag; Vary: Accept-Encoding is added whenever an ETag is set.
+	ServeConditional(modTime time.Time, etag string, write func() error) error
+
+	// Method is a shortcut that returns the request's HTTP method
+	Method() string
+
+	// Path is a shortcut that returns the path of the request's URL
+	Path() string
+
 	// GetRunningTime returns the amount of time during which this request has been running
 	GetRunningTime() time.Duration
+
+	// RemainingTime returns the time left before the request's deadline expires. The second
+	// return value is false if the request has no deadline
+	RemainingTime() (time.Duration, bool)
+
+	// RemoteAddr returns the address of the client that made the request, without the port.
+	// If the server has trusted proxies, this is the same as Request().ClientIP(); otherwise,
+	// it is the host portion of the request's RemoteAddr
+	RemoteAddr() string
+
+	// NewID returns a new unique identifier generated by the server's IDGenerator
+	NewID() string
+
+	// Tag attaches a key/value pair to the request, for example to identify the user who made it.
+	// Tags are emitted by the loggers in the middleware package
+	Tag(key string, value interface{})
+
+	// Tags returns a copy of the tags attached to the request
+	Tags() map[string]interface{}
+
+	// SetFlag turns the feature flag called `name` on or off for the current request
+	SetFlag(name string, on bool)
+
+	// Flag returns true if the feature flag called `name` has been turned on for the current
+	// request, and false otherwise
+	Flag(name string) bool
+
+	// StartPhase starts timing the phase of the request called `name` (for example, routing)
+	// and returns a function that stops the timer. Only the first call to the returned function
+	// has an effect; if a phase is timed more than once, the durations are added up
+	StartPhase(name string) func()
+
+	// Phases returns a copy of the time spent in each of the request's phases so far
+	Phases() map[string]time.Duration
+
+	// CheckDeadline returns a 503 Error if the request's deadline has passed, and nil otherwise.
+	// Handlers can use it to avoid starting expensive work whose result would arrive too late
+	CheckDeadline() error
+
+	// StdContext returns the request's context.Context, which carries its cancellation signal,
+	// deadline, and any values (like tracing spans) attached by other middleware
+	StdContext() context.Context
+
+	// WithStdContext replaces the request's context.Context with `ctx`, so that, for example, a
+	// deadline set by a middleware propagates to the database calls made by later handlers
+	WithStdContext(ctx context.Context)
+
+	// Defer registers fn to be run after the middleware chain has completed, even if it panicked.
+	// Functions are run in last-in, first-out order, like Go's defer statement
+	Defer(fn func())
+
+	// Go runs fn in a new goroutine that the server tracks, so that Server.Shutdown can wait
+	// for it to complete
+	Go(fn func())
+
+	// RecordPanic counts a panic recovered while handling the request in the Panics field of
+	// the server's Stats
+	RecordPanic()
 }
 
 var _ Context = &ContextInstance{}
 
 // Struct ContextInstance is a concrete implementation of the base server context. Your application
 // can safely incorporate it into its own structs to extend the functionality provided by
-// Bowtie
+// Bowtie. Get and Set can be called concurrently, for example from goroutines started with Go
 type ContextInstance struct {
 	r         *Request
 	w         ResponseWriter
 	values    map[ContextKey]interface{}
+	valuesMu  sync.RWMutex
 	startTime time.Time
+	wg        *sync.WaitGroup
+	deferred  []func()
+	newID     IDGenerator
+	tags      map[string]interface{}
+	flags     map[string]bool
+	phases    map[string]time.Duration
+	panics    *int64
 }
 
 // NewContext is a ContextFactory that creates a basic context. You will probably want to create
 // your own context and context factory that extends the basic context for your uses
 func NewContext(r *http.Request, w http.ResponseWriter) Context {
+	return newContext(r, w, &sync.WaitGroup{})
+}
+
+func newContext(r *http.Request, w http.ResponseWriter, wg *sync.WaitGroup) *ContextInstance {
 	return &ContextInstance{
 		r:         NewRequest(r),
 		w:         NewResponseWriter(w),
 		values:    map[ContextKey]interface{}{},
-		startTime: time.Now(),
+		startTime: DefaultClock.Now(),
+		wg:        wg,
+		newID:     NewID,
+		tags:      map[string]interface{}{},
+		flags:     map[string]bool{},
+		phases:    map[string]time.Duration{},
 	}
 }
 
@@ -68,10 +173,16 @@ func (c *ContextInstance) Request() *Request {
 }
 
 func (c *ContextInstance) Get(key ContextKey) interface{} {
+	c.valuesMu.RLock()
+	defer c.valuesMu.RUnlock()
+
 	return c.values[key]
 }
 
 func (c *ContextInstance) Set(key ContextKey, value interface{}) {
+	c.valuesMu.Lock()
+	defer c.valuesMu.Unlock()
+
 	c.values[key] = value
 }
 
@@ -80,7 +191,198 @@ func (c *ContextInstance) Response() ResponseWriter {
 	return c.w
 }
 
+// Respond sets the response's status to `status`, serializes `body` to JSON (unless it is nil)
+// and writes it to the output stream. Because the response is marked as written, no further
+// middleware runs after the current one
+func (c *ContextInstance) Respond(status int, body interface{}) {
+	if body == nil {
+		c.w.WriteHeader(status)
+		return
+	}
+
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(status)
+	c.w.WriteJSON(body)
+}
+
+// ServeConditional handles conditional GET and HEAD requests. If the request's If-None-Match
+// or If-Modified-Since headers show that the client already has the current version of the
+// resource, identified by `etag` and `modTime`, a 304 Not Modified status is written.
+// Otherwise, the ETag and Last-Modified headers are set and `write` is called to output the
+// resource. Either `etag` or `modTime` can be left empty.
+//
+// If the Content-Encoding header has already been set (for example, because the resource is
+// served precompressed), the encoding is appended to the ETag, so that each representation
+// has its own tag; Vary: Accept-Encoding is added whenever an ETag is set.
+func (c *ContextInstance) ServeConditional(modTime time.Time, etag string, write func() error) error {
+	return serveConditional(c.w, c.r, modTime, etag, write)
+}
+
+// Method is a shortcut that returns the request's HTTP method
+func (c *ContextInstance) Method() string {
+	return c.r.Method
+}
+
+// Path is a shortcut that returns the path of the request's URL
+func (c *ContextInstance) Path() string {
+	if c.r.URL == nil {
+		return ""
+	}
+
+	return c.r.URL.Path
+}
+
 // GetRunningTime returns the amount of time during which this request has been running
 func (c *ContextInstance) GetRunningTime() time.Duration {
-	return time.Now().Sub(c.startTime)
+	return DefaultClock.Now().Sub(c.startTime)
+}
+
+// resetStart restarts the clock used by GetRunningTime. The server calls it when it begins
+// running a request, so that contexts that are reused report the correct running time
+func (c *ContextInstance) resetStart() {
+	c.startTime = DefaultClock.Now()
+}
+
+// RemainingTime returns the time left before the request's deadline expires. The second
+// return value is false if the request has no deadline. Because deadlines are enforced by
+// the timers of context.Context, the remaining time is measured against the system time,
+// rather than DefaultClock
+func (c *ContextInstance) RemainingTime() (time.Duration, bool) {
+	deadline, ok := c.r.Context().Deadline()
+
+	if !ok {
+		return 0, false
+	}
+
+	return time.Until(deadline), true
+}
+
+// StartPhase starts timing the phase of the request called `name` (for example, routing)
+// and returns a function that stops the timer. Only the first call to the returned function
+// has an effect; if a phase is timed more than once, the durations are added up
+func (c *ContextInstance) StartPhase(name string) func() {
+	start := DefaultClock.Now()
+	stopped := false
+
+	return func() {
+		if stopped {
+			return
+		}
+
+		stopped = true
+		c.phases[name] += DefaultClock.Now().Sub(start)
+	}
+}
+
+// Phases returns a copy of the time spent in each of the request's phases so far
+func (c *ContextInstance) Phases() map[string]time.Duration {
+	result := make(map[string]time.Duration, len(c.phases))
+
+	for name, d := range c.phases {
+		result[name] = d
+	}
+
+	return result
+}
+
+// CheckDeadline returns a 503 Error if the request's deadline has passed, and nil otherwise.
+// Handlers can use it to avoid starting expensive work whose result would arrive too late
+func (c *ContextInstance) CheckDeadline() error {
+	if remaining, ok := c.RemainingTime(); ok && remaining <= 0 {
+		return NewError(http.StatusServiceUnavailable, "Request deadline exceeded")
+	}
+
+	return nil
+}
+
+// StdContext returns the request's context.Context, which carries its cancellation signal,
+// deadline, and any values (like tracing spans) attached by other middleware
+func (c *ContextInstance) StdContext() context.Context {
+	return c.r.Context()
+}
+
+// WithStdContext replaces the request's context.Context with `ctx`, so that, for example, a
+// deadline set by a middleware propagates to the database calls made by later handlers
+func (c *ContextInstance) WithStdContext(ctx context.Context) {
+	c.r.Request = c.r.WithContext(ctx)
+}
+
+// RemoteAddr returns the address of the client that made the request, without the port.
+// If the server has trusted proxies, this is the same as Request().ClientIP(); otherwise,
+// it is the host portion of the request's RemoteAddr
+func (c *ContextInstance) RemoteAddr() string {
+	if len(c.r.trustedProxies) > 0 {
+		return c.r.ClientIP()
+	}
+
+	return c.r.remoteHost()
+}
+
+// NewID returns a new unique identifier generated by the server's IDGenerator
+func (c *ContextInstance) NewID() string {
+	return c.newID()
+}
+
+// Tag attaches a key/value pair to the request, for example to identify the user who made it.
+// Tags are emitted by the loggers in the middleware package
+func (c *ContextInstance) Tag(key string, value interface{}) {
+	c.tags[key] = value
+}
+
+// Tags returns a copy of the tags attached to the request
+func (c *ContextInstance) Tags() map[string]interface{} {
+	result := make(map[string]interface{}, len(c.tags))
+
+	for key, value := range c.tags {
+		result[key] = value
+	}
+
+	return result
+}
+
+// SetFlag turns the feature flag called `name` on or off for the current request
+func (c *ContextInstance) SetFlag(name string, on bool) {
+	c.flags[name] = on
+}
+
+// Flag returns true if the feature flag called `name` has been turned on for the current
+// request, and false otherwise
+func (c *ContextInstance) Flag(name string) bool {
+	return c.flags[name]
+}
+
+// Defer registers fn to be run after the middleware chain has completed, even if it panicked.
+// Functions are run in last-in, first-out order, like Go's defer statement
+func (c *ContextInstance) Defer(fn func()) {
+	c.deferred = append(c.deferred, fn)
+}
+
+// runDeferred runs the functions registered with Defer in reverse order
+func (c *ContextInstance) runDeferred() {
+	for len(c.deferred) > 0 {
+		fn := c.deferred[len(c.deferred)-1]
+		c.deferred = c.deferred[:len(c.deferred)-1]
+
+		fn()
+	}
+}
+
+// Go runs fn in a new goroutine that the server tracks, so that Server.Shutdown can wait
+// for it to complete
+func (c *ContextInstance) Go(fn func()) {
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		fn()
+	}()
+}
+
+// RecordPanic counts a panic recovered while handling the request in the Panics field of
+// the server's Stats. It has no effect on contexts that weren't created by a server
+func (c *ContextInstance) RecordPanic() {
+	if c.panics != nil {
+		atomic.AddInt64(c.panics, 1)
+	}
 }
@@ -1,9 +1,13 @@
 package bowtie
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type localContext struct {
@@ -90,3 +94,173 @@ func TestContext(t *testing.T) {
 		t.Error("Context unexpectedly has no errors after writing JSON with error")
 	}
 }
+
+func TestContextMethodAndPath(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/test/path?x=1", nil)
+	c := NewContext(r, newMockWriter())
+
+	if c.Method() != r.Method {
+		t.Errorf("Expected method %s, got %s instead", r.Method, c.Method())
+	}
+
+	if c.Path() != r.URL.Path {
+		t.Errorf("Expected path %s, got %s instead", r.URL.Path, c.Path())
+	}
+}
+
+func TestContextNewID(t *testing.T) {
+	c := NewContext(&http.Request{}, newMockWriter())
+
+	a, b := c.NewID(), c.NewID()
+
+	if len(a) != 36 || a == b {
+		t.Errorf("Unexpected IDs generated: %s, %s", a, b)
+	}
+}
+
+func TestContextServeConditional(t *testing.T) {
+	modTime := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	expect := func(header, value string, status int, body string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+
+		w := newMockWriter()
+		c := NewContext(r, w)
+
+		err := c.ServeConditional(modTime, "v1", func() error {
+			_, err := c.Response().WriteString("resource")
+			return err
+		})
+
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+
+		if w.status != status || string(w.written) != body {
+			t.Errorf("Unexpected response for %s=%q: %d %s", header, value, w.status, w.written)
+		}
+
+		if w.header.Get("ETag") != `"v1"` || w.header.Get("Last-Modified") != "Sun, 01 Mar 2015 12:00:00 GMT" {
+			t.Errorf("Unexpected validators: %#v", w.header)
+		}
+	}
+
+	expect("", "", 0, "resource")
+	expect("If-None-Match", `"v1"`, http.StatusNotModified, "")
+	expect("If-None-Match", `"v0"`, 0, "resource")
+	expect("If-Modified-Since", "Sun, 01 Mar 2015 12:00:00 GMT", http.StatusNotModified, "")
+	expect("If-Modified-Since", "Sat, 28 Feb 2015 12:00:00 GMT", 0, "resource")
+}
+
+func TestContextCheckDeadline(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	if err := NewContext(r, newMockWriter()).CheckDeadline(); err != nil {
+		t.Errorf("Unexpected error for a request without a deadline: %s", err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	err := NewContext(r.WithContext(ctx), newMockWriter()).CheckDeadline()
+
+	if e, ok := err.(Error); !ok || e.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error for an expired deadline, got %v instead", err)
+	}
+}
+
+func TestContextServeConditionalEncoding(t *testing.T) {
+	expect := func(acceptEncoding, ifNoneMatch string, status int, etag string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+
+		w := newMockWriter()
+		c := NewContext(r, w)
+
+		if strings.Contains(acceptEncoding, "gzip") {
+			c.Response().Header().Set("Content-Encoding", "gzip")
+		}
+
+		c.ServeConditional(time.Time{}, "v1", func() error {
+			_, err := c.Response().WriteString("resource")
+			return err
+		})
+
+		if w.status != status {
+			t.Errorf("Expected status %d for %q (%s), got %d instead", status, acceptEncoding, ifNoneMatch, w.status)
+		}
+
+		if w.header.Get("ETag") != etag {
+			t.Errorf("Expected ETag %s for %q, got %s instead", etag, acceptEncoding, w.header.Get("ETag"))
+		}
+
+		if w.header.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Unexpected Vary header: %q", w.header.Get("Vary"))
+		}
+	}
+
+	expect("", "", 0, `"v1"`)
+	expect("gzip, deflate", "", 0, `"v1-gzip"`)
+	expect("gzip", `"v1-gzip"`, http.StatusNotModified, `"v1-gzip"`)
+	expect("gzip", `"v1"`, 0, `"v1-gzip"`)
+	expect("", `"v1-gzip"`, 0, `"v1"`)
+}
+
+func TestContextConcurrentSet(t *testing.T) {
+	c := NewContext(&http.Request{}, newMockWriter())
+	key := GenerateContextKey()
+
+	wg := sync.WaitGroup{}
+
+	for index := 0; index < 20; index++ {
+		wg.Add(1)
+
+		go func(index int) {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				c.Set(key, index)
+				c.Get(key)
+			}
+		}(index)
+	}
+
+	wg.Wait()
+
+	if value, ok := c.Get(key).(int); !ok || value < 0 || value >= 20 {
+		t.Errorf("Unexpected value after concurrent writes: %#v", c.Get(key))
+	}
+}
+
+func TestContextStdContext(t *testing.T) {
+	type key struct{}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	c := NewContext(r, newMockWriter())
+
+	if c.StdContext() != r.Context() {
+		t.Error("Expected the request's context by default")
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(c.StdContext(), key{}, "span"), time.Minute)
+	defer cancel()
+
+	c.WithStdContext(ctx)
+
+	if c.StdContext() != ctx || c.Request().Context() != ctx {
+		t.Error("Expected the replaced context to be used by the request")
+	}
+
+	if c.StdContext().Value(key{}) != "span" {
+		t.Errorf("Unexpected context value: %v", c.StdContext().Value(key{}))
+	}
+
+	if remaining, ok := c.RemainingTime(); !ok || remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Expected the new deadline to be reported, got %v (%v) instead", remaining, ok)
+	}
+}
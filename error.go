@@ -2,7 +2,10 @@ package bowtie
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 )
 
 // Interface Error represents a Bowtie error, which extends the standard error interface to provide
@@ -46,6 +49,7 @@ type ErrorInstance struct {
 	message    string       // A message associated with the error. May be overwritten if the status code is >= 500
 	data       interface{}  // Assorted data associated with the error, for logging purposes
 	stackTrace []StackFrame // The stack trace associated with the error, for logging purposes
+	cause      error        // The error from which this error was built, if any
 }
 
 // NewError builds a new Error instance; the `format` and `arguments` parameters work as in `fmt.Sprintf()`
@@ -62,21 +66,69 @@ func NewError(statusCode int, format string, arguments ...interface{}) Error {
 //
 // If the error
 func NewErrorWithError(err error) Error {
+	if e, ok := err.(*ProblemError); ok {
+		problem := *e
+
+		return &problem
+	}
+
 	if e, ok := err.(Error); ok {
 		return &ErrorInstance{
 			statusCode: e.StatusCode(),
 			message:    e.Message(),
 			data:       e.Data(),
 			stackTrace: e.StackTrace(),
+			cause:      errors.Unwrap(e),
 		}
 	}
 
 	return &ErrorInstance{
 		statusCode: 500,
 		message:    err.Error(),
+		cause:      err,
 	}
 }
 
+// NewNotAcceptableError builds a 406 Not Acceptable Error whose message lists the media types
+// that are `available`. The list is also retained as the error's data.
+func NewNotAcceptableError(available []string) Error {
+	e := NewError(http.StatusNotAcceptable, "Not acceptable; available representations: %s", strings.Join(available, ", "))
+	e.SetData(available)
+
+	return e
+}
+
+// NewErrorFromPanic builds a 500 Error out of a value returned by recover(), so that every
+// recovery handler reports panics in the same way. Errors, strings, and fmt.Stringers are
+// turned into messages directly; any other value is formatted with `%#v`. The recovered
+// value itself is retained as the error's data.
+func NewErrorFromPanic(recovered interface{}) Error {
+	var message string
+
+	switch v := recovered.(type) {
+	case error:
+		message = v.Error()
+	case string:
+		message = v
+	case fmt.Stringer:
+		message = v.String()
+	default:
+		message = fmt.Sprintf("%#v", v)
+	}
+
+	e := NewError(500, "panic: %s", message)
+	e.SetData(recovered)
+
+	return e
+}
+
+// ErrorStatusCodeField and ErrorMessageField hold the names of the fields used when an ErrorInstance
+// is marshalled to JSON. You can change them if your clients expect different names.
+var (
+	ErrorStatusCodeField = "statusCode"
+	ErrorMessageField    = "message"
+)
+
 // Ensure that ErrorInstance always satisfies Error
 
 var _ Error = &ErrorInstance{}
@@ -96,8 +148,8 @@ func (e *ErrorInstance) String() string {
 
 func (e *ErrorInstance) MarshalJSON() ([]byte, error) {
 	result := map[string]interface{}{
-		"statusCode": e.statusCode,
-		"message":    e.Error(),
+		ErrorStatusCodeField: e.statusCode,
+		ErrorMessageField:    e.Error(),
 	}
 
 	return json.Marshal(result)
@@ -125,14 +177,65 @@ func (e *ErrorInstance) SetData(data interface{}) {
 	e.data = data
 }
 
-// Returns a private representation of e
+// Returns the error from which e was built, if any, so that e can be inspected with errors.Is
+// and errors.As
+func (e *ErrorInstance) Unwrap() error {
+	return e.cause
+}
+
+// Returns a private representation of e. If e was built from another error, the representation
+// includes the whole chain of wrapped errors under `causes`, starting from the outermost one
 func (e *ErrorInstance) PrivateRepresentation() map[string]interface{} {
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"statusCode": e.statusCode,
 		"message":    e.message,
 		"data":       e.data,
 		"stackTrace": e.stackTrace,
 	}
+
+	causes := []map[string]interface{}{}
+
+	for cause := e.cause; cause != nil; cause = errors.Unwrap(cause) {
+		causes = append(causes, map[string]interface{}{
+			"message": cause.Error(),
+			"type":    fmt.Sprintf("%T", cause),
+		})
+	}
+
+	if len(causes) > 0 {
+		result["causes"] = causes
+	}
+
+	return result
+}
+
+// errorsJSON marshals `errs` to a JSON array of their public representations. If
+// `includePrivate` is true, each error's private representation is added to it under `private`
+func errorsJSON(errs []Error, includePrivate bool) ([]byte, error) {
+	if !includePrivate {
+		return json.Marshal(errs)
+	}
+
+	result := make([]map[string]interface{}, len(errs))
+
+	for index, err := range errs {
+		public, marshalErr := json.Marshal(err)
+
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		entry := map[string]interface{}{}
+
+		if marshalErr := json.Unmarshal(public, &entry); marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		entry["private"] = err.PrivateRepresentation()
+		result[index] = entry
+	}
+
+	return json.Marshal(result)
 }
 
 func (e *ErrorInstance) StackTrace() []StackFrame {
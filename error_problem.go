@@ -0,0 +1,129 @@
+package bowtie
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Struct ProblemError is an Error that marshals to the Problem Details format described by
+// RFC 7807. When it is added to a response writer, the response's Content-Type is set to
+// `application/problem+json`, and middleware.ErrorReporter outputs it as a single problem
+// object rather than as a list of errors.
+//
+// Like ErrorInstance, ProblemError does not leak its Detail to the public if its Status
+// indicates a server error.
+type ProblemError struct {
+	Type     string // A URI that identifies the problem type; "about:blank" if empty
+	Title    string // A short, human-readable summary of the problem type
+	Status   int    // The HTTP status code
+	Detail   string // A human-readable explanation specific to this occurrence of the problem
+	Instance string // A URI that identifies this specific occurrence of the problem
+
+	data       interface{}
+	stackTrace []StackFrame
+}
+
+// NewProblemError builds a new ProblemError with the given status, title and detail. If `title`
+// is empty, the standard text for `status` is used instead
+func NewProblemError(status int, title, detail string) *ProblemError {
+	if title == "" {
+		title = http.StatusText(status)
+	}
+
+	return &ProblemError{
+		Status: status,
+		Title:  title,
+		Detail: detail,
+	}
+}
+
+// Ensure that ProblemError always satisfies Error
+
+var _ Error = &ProblemError{}
+
+// Satisfy the error, fmt.Stringer, and json.Marshaler interfaces
+func (e *ProblemError) Error() string {
+	if e.Status > 499 || e.Detail == "" {
+		return e.Title
+	}
+
+	return e.Detail
+}
+
+func (e *ProblemError) String() string {
+	return e.Error()
+}
+
+func (e *ProblemError) MarshalJSON() ([]byte, error) {
+	result := map[string]interface{}{
+		"type":   e.Type,
+		"title":  e.Title,
+		"status": e.Status,
+	}
+
+	if e.Type == "" {
+		result["type"] = "about:blank"
+	}
+
+	if e.Detail != "" && e.Status < 500 {
+		result["detail"] = e.Detail
+	}
+
+	if e.Instance != "" {
+		result["instance"] = e.Instance
+	}
+
+	return json.Marshal(result)
+}
+
+// Satisfy the Error interface
+
+// Returns the status code associated with e
+func (e *ProblemError) StatusCode() int {
+	return e.Status
+}
+
+// Returns the detail associated with e, or its title if no detail is available
+func (e *ProblemError) Message() string {
+	if e.Detail == "" {
+		return e.Title
+	}
+
+	return e.Detail
+}
+
+// Returns the data associated with e
+func (e *ProblemError) Data() interface{} {
+	return e.data
+}
+
+// Sets the data associated with e
+func (e *ProblemError) SetData(data interface{}) {
+	e.data = data
+}
+
+// Returns a private representation of e
+func (e *ProblemError) PrivateRepresentation() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       e.Type,
+		"title":      e.Title,
+		"statusCode": e.Status,
+		"message":    e.Detail,
+		"instance":   e.Instance,
+		"data":       e.data,
+		"stackTrace": e.stackTrace,
+	}
+}
+
+func (e *ProblemError) StackTrace() []StackFrame {
+	return e.stackTrace
+}
+
+func (e *ProblemError) CaptureStackTrace() Error {
+	e.stackTrace = stack(2)
+
+	return e
+}
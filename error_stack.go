@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"runtime"
+	"strings"
 )
 
 // Stack trace code heavily borrowed from https://github.com/go-martini/martini/blob/master/recovery.go
@@ -16,6 +17,11 @@ type StackFrame struct {
 	Source string `json:"source"`
 }
 
+// CaptureSource determines whether stack traces include the source line of each frame. Reading
+// the source requires loading every file in the trace from disk, so you may want to turn this off
+// in production; function names, paths, and line numbers are still recorded when it is disabled.
+var CaptureSource = true
+
 var (
 	dunno     = []byte("???")
 	centerDot = []byte("·")
@@ -48,6 +54,13 @@ func stack(skip int) []StackFrame {
 			Line: line,
 		}
 
+		frame.Func = string(function(pc))
+
+		if !CaptureSource {
+			result = append(result, frame)
+			continue
+		}
+
 		if file != lastFile {
 			data, err := ioutil.ReadFile(file)
 			if err != nil {
@@ -57,7 +70,6 @@ func stack(skip int) []StackFrame {
 			lastFile = file
 		}
 
-		frame.Func = string(function(pc))
 		frame.Source = string(source(lines, line))
 
 		result = append(result, frame)
@@ -105,3 +117,31 @@ func (e *ErrorInstance) CaptureStackTrace() Error {
 
 	return e
 }
+
+// captureCallerStackTrace captures a stack trace that starts at the first caller outside of
+// Bowtie's own package, so that automatically captured traces point at application code
+func (e *ErrorInstance) captureCallerStackTrace() {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	prefix := name[:strings.LastIndex(name, "/")+1]
+	prefix += strings.SplitN(name[len(prefix):], ".", 2)[0] + "."
+
+	skip := 1
+
+	for {
+		pc, _, _, ok := runtime.Caller(skip)
+
+		if !ok {
+			break
+		}
+
+		if fn := runtime.FuncForPC(pc); fn == nil || !strings.HasPrefix(fn.Name(), prefix) {
+			break
+		}
+
+		skip += 1
+	}
+
+	// stack() adds a frame of its own
+	e.stackTrace = stack(skip + 1)
+}
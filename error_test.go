@@ -3,6 +3,7 @@ package bowtie
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -40,3 +41,133 @@ func TestError(t *testing.T) {
 		t.Errorf("Unexpected stack trace: %#v", e.StackTrace())
 	}
 }
+
+func TestErrorStackTraceWithoutSource(t *testing.T) {
+	CaptureSource = false
+	defer func() { CaptureSource = true }()
+
+	e := NewError(500, "Test").CaptureStackTrace()
+
+	if len(e.StackTrace()) == 0 {
+		t.Fatal("Expected a stack trace, got none")
+	}
+
+	for _, frame := range e.StackTrace() {
+		if frame.Source != "" {
+			t.Errorf("Expected no source, got %s instead", frame.Source)
+		}
+
+		if frame.Path == "" || frame.Line == 0 || frame.Func == "" {
+			t.Errorf("Incomplete stack frame: %#v", frame)
+		}
+	}
+}
+
+func BenchmarkCaptureStackTrace(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewError(500, "Test").CaptureStackTrace()
+	}
+}
+
+func BenchmarkCaptureStackTraceWithoutSource(b *testing.B) {
+	CaptureSource = false
+	defer func() { CaptureSource = true }()
+
+	for i := 0; i < b.N; i++ {
+		NewError(500, "Test").CaptureStackTrace()
+	}
+}
+
+func TestErrorCustomJSONFields(t *testing.T) {
+	ErrorStatusCodeField = "code"
+	ErrorMessageField = "detail"
+
+	defer func() {
+		ErrorStatusCodeField = "statusCode"
+		ErrorMessageField = "message"
+	}()
+
+	data, err := json.Marshal(NewError(404, "Not found"))
+
+	if err != nil {
+		t.Fatalf("Unable to marshal Error instance to JSON: %s", err)
+	}
+
+	if string(data) != `{"code":404,"detail":"Not found"}` {
+		t.Errorf("Unexpected JSON marshal received: %s", string(data))
+	}
+}
+
+func TestProblemErrorHidesServerDetail(t *testing.T) {
+	e := NewProblemError(503, "", "Database connection refused")
+
+	data, err := json.Marshal(NewErrorWithError(e))
+
+	if err != nil {
+		t.Fatalf("Unable to marshal ProblemError to JSON: %s", err)
+	}
+
+	if string(data) != `{"status":503,"title":"Service Unavailable","type":"about:blank"}` {
+		t.Errorf("Unexpected JSON marshal received: %s", string(data))
+	}
+}
+
+type panicValue struct {
+	Code int
+}
+
+func TestNewErrorFromPanic(t *testing.T) {
+	expect := func(recovered interface{}, message string) {
+		e := NewErrorFromPanic(recovered)
+
+		if e.StatusCode() != 500 {
+			t.Errorf("Expected status 500, got %d instead", e.StatusCode())
+		}
+
+		if e.Message() != message {
+			t.Errorf("Expected message %q, got %q instead", message, e.Message())
+		}
+
+		if e.Data() != recovered {
+			t.Errorf("Expected the panic value to be retained, got %#v instead", e.Data())
+		}
+	}
+
+	err := errors.New("Something broke")
+
+	expect(err, "panic: Something broke")
+	expect("test", "panic: test")
+	expect(panicValue{Code: 42}, "panic: bowtie.panicValue{Code:42}")
+}
+
+func TestErrorCauseChain(t *testing.T) {
+	root := errors.New("connection refused")
+	middle := fmt.Errorf("query failed: %w", root)
+	outer := fmt.Errorf("loading user: %w", middle)
+
+	e := NewErrorWithError(outer)
+
+	if !errors.Is(e, root) {
+		t.Error("Expected the error to wrap its root cause")
+	}
+
+	causes, ok := e.PrivateRepresentation()["causes"].([]map[string]interface{})
+
+	if !ok || len(causes) != 3 {
+		t.Fatalf("Expected a chain of 3 causes, got %v instead", causes)
+	}
+
+	expect := func(index int, message, typeName string) {
+		if causes[index]["message"] != message || causes[index]["type"] != typeName {
+			t.Errorf("Unexpected cause %d: %v", index, causes[index])
+		}
+	}
+
+	expect(0, "loading user: query failed: connection refused", "*fmt.wrapError")
+	expect(1, "query failed: connection refused", "*fmt.wrapError")
+	expect(2, "connection refused", "*errors.errorString")
+
+	if _, ok := NewError(400, "Bad request").PrivateRepresentation()["causes"]; ok {
+		t.Error("Expected no causes for an error that doesn't wrap another")
+	}
+}
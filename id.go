@@ -0,0 +1,26 @@
+package bowtie
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// IDGenerator is a function that returns a new, unique identifier. Middlewares that need random
+// identifiers (like middleware.RequestID) obtain them through Context.NewID(), so that tests can
+// inject a deterministic generator by setting the IDGenerator property of the server.
+type IDGenerator func() string
+
+// NewID is the default IDGenerator. It returns a random, UUID-formatted identifier
+func NewID() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	// Set the version (4) and variant bits
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
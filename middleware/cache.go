@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Struct CachedResponse is a complete response stored by the Cache middleware
+type CachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Expires time.Time
+}
+
+// Interface Store is the storage used by the Cache middleware. Implementations must be safe
+// for concurrent use
+type Store interface {
+	// Get returns the response stored under `key`, if any
+	Get(key string) (*CachedResponse, bool)
+
+	// Set stores `response` under `key`, replacing any previous response
+	Set(key string, response *CachedResponse)
+}
+
+// Struct MemoryStore is a Store that keeps responses in memory. Expired responses are removed
+// when they are next looked up
+type MemoryStore struct {
+	mutex     sync.Mutex
+	responses map[string]*CachedResponse
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates a new, empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		responses: map[string]*CachedResponse{},
+	}
+}
+
+// Get returns the response stored under `key`, unless it has expired
+func (m *MemoryStore) Get(key string) (*CachedResponse, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response, ok := m.responses[key]
+
+	if ok && !bowtie.DefaultClock.Now().Before(response.Expires) {
+		delete(m.responses, key)
+		return nil, false
+	}
+
+	return response, ok
+}
+
+// Set stores `response` under `key`
+func (m *MemoryStore) Set(key string, response *CachedResponse) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.responses[key] = response
+}
+
+// Cache returns a middleware that caches successful (2xx) responses to GET requests in memory
+// for `ttl`, and serves the cached copy, including its status and headers, to the requests that
+// arrive in the meantime. Responses are stored under the key returned by `keyFunc`, or under
+// the request's URL if `keyFunc` is nil:
+//
+//  s.AddMiddleware(middleware.Cache(5*time.Second, nil))
+//  s.AddMiddlewareProvider(router)
+//
+// Because the whole response must be captured, Cache turns on buffering for the requests it
+// handles. Responses that have errors are never cached, and Set-Cookie headers are never
+// stored, so that one client's session isn't handed to another. Only the headers set by the
+// middleware that runs after Cache are stored; those set earlier in the chain (for example,
+// by RequestID or CORSHandler) are computed afresh for every request. Unless you provide a
+// `keyFunc`, which is then responsible for keeping the responses of different users apart,
+// requests that carry an Authorization or Cookie header bypass the cache altogether.
+func Cache(ttl time.Duration, keyFunc func(c bowtie.Context) string) bowtie.Middleware {
+	return CacheWithStore(NewMemoryStore(), ttl, keyFunc)
+}
+
+// CacheWithStore works like Cache, but keeps responses in `store`
+func CacheWithStore(store Store, ttl time.Duration, keyFunc func(c bowtie.Context) string) bowtie.Middleware {
+	skipCredentials := keyFunc == nil
+
+	if keyFunc == nil {
+		keyFunc = requestURL
+	}
+
+	return func(c bowtie.Context, next func()) {
+		if c.Method() != "GET" || (skipCredentials && hasCredentials(c)) {
+			next()
+			return
+		}
+
+		res := c.Response()
+		key := keyFunc(c)
+
+		if cached, ok := store.Get(key); ok && bowtie.DefaultClock.Now().Before(cached.Expires) {
+			writeCachedResponse(res, cached)
+			return
+		}
+
+		before := res.Header().Clone()
+
+		res.SetBuffered(true)
+
+		next()
+
+		if res.Status() < 200 || res.Status() >= 300 {
+			return
+		}
+
+		if response := captureResponse(res, before); response != nil {
+			response.Expires = bowtie.DefaultClock.Now().Add(ttl)
+			store.Set(key, response)
+		}
+	}
+}
+
+// captureResponse returns a copy of the buffered response in `res`, or nil if the response
+// isn't buffered or has errors. Only the headers that differ from `before`, a snapshot taken
+// before the rest of the chain ran, are copied, and Set-Cookie headers never are
+func captureResponse(res bowtie.ResponseWriter, before http.Header) *CachedResponse {
+	body := res.BufferedBody()
+
+	if body == nil || res.HasError() {
+		return nil
+	}
+
+	header := http.Header{}
+
+	for name, values := range res.Header() {
+		if !sameValues(before[name], values) {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+
+	header.Del("Set-Cookie")
+
+	return &CachedResponse{
+		Status: res.Status(),
+		Header: header,
+		Body:   append([]byte(nil), body...),
+	}
+}
+
+// sameValues returns true if `a` and `b` hold the same header values, in the same order
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeCachedResponse writes the status, headers, and body of `cached` to `res`
+func writeCachedResponse(res bowtie.ResponseWriter, cached *CachedResponse) {
+	header := res.Header()
+
+	for name, values := range cached.Header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	res.WriteHeader(cached.Status)
+	res.Write(cached.Body)
+}
+
+// hasCredentials returns true if the request carries an Authorization or Cookie header, in
+// which case its response may be specific to the user who made it
+func hasCredentials(c bowtie.Context) bool {
+	header := c.Request().Header
+
+	return header.Get("Authorization") != "" || header.Get("Cookie") != ""
+}
+
+// requestURL is the default key function of Cache and SingleFlight
+func requestURL(c bowtie.Context) string {
+	return c.Request().URL.String()
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	clock := bowtie.NewFakeClock(time.Now())
+
+	bowtie.DefaultClock = clock
+	defer func() { bowtie.DefaultClock = bowtie.RealClock }()
+
+	calls := 0
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Cache(10*time.Second, nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		calls += 1
+
+		if c.Path() == "/missing" {
+			c.Response().WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		c.Response().Header().Set("X-Version", fmt.Sprintf("%d", calls))
+		c.Response().WriteHeader(http.StatusCreated)
+		c.Response().WriteString(fmt.Sprintf("call %d", calls))
+	})
+
+	expect := func(method, path string, status int, body string, expectedCalls int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status || w.Body.String() != body {
+			t.Errorf("Unexpected response for %s %s: %d %s", method, path, w.Code, w.Body.String())
+		}
+
+		if status == http.StatusCreated && w.Header().Get("X-Version") != body[5:] {
+			t.Errorf("Unexpected X-Version header for %s %s: %s", method, path, w.Header().Get("X-Version"))
+		}
+
+		if calls != expectedCalls {
+			t.Errorf("Expected %d handler calls after %s %s, got %d instead", expectedCalls, method, path, calls)
+		}
+	}
+
+	expect("GET", "/", http.StatusCreated, "call 1", 1)
+	expect("GET", "/", http.StatusCreated, "call 1", 1)
+	expect("POST", "/", http.StatusCreated, "call 2", 2)
+	expect("GET", "/missing", http.StatusNotFound, "", 3)
+	expect("GET", "/missing", http.StatusNotFound, "", 4)
+
+	clock.Advance(9 * time.Second)
+
+	expect("GET", "/", http.StatusCreated, "call 1", 4)
+
+	clock.Advance(time.Second)
+
+	expect("GET", "/", http.StatusCreated, "call 5", 5)
+	expect("GET", "/", http.StatusCreated, "call 5", 5)
+}
+
+func TestCacheUpstreamHeaders(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(RequestID("X-Request-Id"))
+	s.AddMiddleware(Cache(10*time.Second, nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().Header().Set("X-Version", "1")
+		c.Response().WriteString("ok")
+	})
+
+	for _, id := range []string{"aaa", "bbb"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-Id", id)
+
+		s.ServeHTTP(w, req)
+
+		if w.Header().Get("X-Request-Id") != id || w.Header().Get("X-Version") != "1" {
+			t.Errorf("Unexpected headers for request %s: %#v", id, w.Header())
+		}
+	}
+}
+
+func TestCachePrivateResponses(t *testing.T) {
+	calls := 0
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Cache(time.Minute, nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		calls += 1
+
+		http.SetCookie(c.Response(), &http.Cookie{Name: "session", Value: fmt.Sprintf("%d", calls)})
+		c.Response().WriteString(fmt.Sprintf("call %d", calls))
+	})
+
+	expect := func(header, value, body, cookie string, expectedCalls int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		s.ServeHTTP(w, req)
+
+		if w.Body.String() != body || w.Header().Get("Set-Cookie") != cookie {
+			t.Errorf("Unexpected response with %s: %s %q", header, w.Body.String(), w.Header().Get("Set-Cookie"))
+		}
+
+		if calls != expectedCalls {
+			t.Errorf("Expected %d handler calls with %s, got %d instead", expectedCalls, header, calls)
+		}
+	}
+
+	expect("Cookie", "session=a", "call 1", "session=1", 1)
+	expect("Authorization", "Bearer b", "call 2", "session=2", 2)
+	expect("", "", "call 3", "session=3", 3)
+	expect("", "", "call 3", "", 3)
+	expect("Cookie", "session=a", "call 4", "session=4", 4)
+}
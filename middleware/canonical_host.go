@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"strings"
+)
+
+// CanonicalHost returns a middleware that redirects requests whose Host header differs from
+// `host` to the same path and query on `host`, using `code` as the status (typically 301, or
+// 308 to preserve the request method). Hosts are compared case-insensitively, so requests
+// that already target the canonical host are never redirected. The redirect keeps the scheme
+// of the request, as reported by bowtie.Request.Scheme, so that requests forwarded over HTTPS
+// by a trusted proxy are redirected to HTTPS.
+func CanonicalHost(host string, code int) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		req := c.Request()
+
+		if host == "" || strings.EqualFold(req.Host, host) {
+			return
+		}
+
+		http.Redirect(c.Response(), req.Request, req.Scheme()+"://"+host+req.URL.RequestURI(), code)
+	}
+}
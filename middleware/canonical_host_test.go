@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(CanonicalHost("www.example.com", http.StatusPermanentRedirect))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://WWW.example.com/test?x=1", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Unexpected redirect for the canonical host: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "http://example.com/test?x=1", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPermanentRedirect {
+		t.Errorf("Expected status %d, got %d instead", http.StatusPermanentRedirect, w.Code)
+	}
+
+	if location := w.Header().Get("Location"); location != "http://www.example.com/test?x=1" {
+		t.Errorf("Unexpected redirect location: %s", location)
+	}
+}
+
+func TestCanonicalHostForwardedProto(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.TrustedProxies = []string{"10.0.0.0/8"}
+
+	s.AddMiddleware(CanonicalHost("www.example.com", http.StatusMovedPermanently))
+
+	expect := func(remoteAddr, location string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/test", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		s.ServeHTTP(w, req)
+
+		if l := w.Header().Get("Location"); l != location {
+			t.Errorf("Expected a redirect to %s for %s, got %s instead", location, remoteAddr, l)
+		}
+	}
+
+	expect("10.0.0.1:1234", "https://www.example.com/test")
+	expect("203.0.113.5:1234", "http://www.example.com/test")
+}
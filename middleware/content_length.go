@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+)
+
+// RequireContentLength returns a middleware that bounds the size of request bodies. Requests
+// made with a method that carries a body (POST, PUT, and PATCH) are rejected with a 411 Length
+// Required error if their length is unknown, as is the case with chunked requests, and with a
+// 413 Request Entity Too Large error if their Content-Length exceeds `max` bytes.
+func RequireContentLength(max int64) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		req := c.Request()
+
+		if !req.ExpectsBody() {
+			return
+		}
+
+		if req.ContentLength < 0 {
+			c.Response().AddError(bowtie.NewError(http.StatusLengthRequired, "Content-Length required"))
+			return
+		}
+
+		if req.ContentLength > max {
+			c.Response().AddError(bowtie.NewError(http.StatusRequestEntityTooLarge, "Request body larger than %d bytes", max))
+		}
+	}
+}
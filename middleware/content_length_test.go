@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireContentLength(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(RequireContentLength(16))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	expect := func(method, body string, contentLength int64, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/", strings.NewReader(body))
+		req.ContentLength = contentLength
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s with length %d, got %d instead", status, method, contentLength, w.Code)
+		}
+	}
+
+	expect("POST", "Hello, world", 12, http.StatusOK)
+	expect("POST", "Hello, world", -1, http.StatusLengthRequired)
+	expect("PUT", strings.Repeat("a", 32), 32, http.StatusRequestEntityTooLarge)
+	expect("GET", "", -1, http.StatusOK)
+}
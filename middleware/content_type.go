@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// RequireContentType returns a handle that rejects requests whose Content-Type is not one of
+// `types` with a 415 Unsupported Media Type error. Place it in front of a route's handles to
+// prevent them from running on unsupported input:
+//
+//  r.POST("/users", middleware.RequireContentType("application/json"), createUser)
+//
+// Media type parameters (like charset) are ignored, and requests without a body are always
+// accepted.
+func RequireContentType(types ...string) Handle {
+	return func(c bowtie.Context) {
+		req := c.Request()
+
+		if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+			return
+		}
+
+		contentType := req.Header.Get("Content-Type")
+
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			for _, t := range types {
+				if strings.EqualFold(mediaType, t) {
+					return
+				}
+			}
+		}
+
+		c.Response().AddError(bowtie.NewError(http.StatusUnsupportedMediaType, "Unsupported content type %s", contentType))
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireContentType(t *testing.T) {
+	r := NewRouter()
+
+	r.POST("/test", RequireContentType("application/json"), func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(contentType, body string, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/test", strings.NewReader(body))
+
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %q, got %d instead", status, contentType, w.Code)
+		}
+	}
+
+	expect("application/json; charset=utf-8", "{}", http.StatusOK)
+	expect("text/plain", "{}", http.StatusUnsupportedMediaType)
+	expect("", "{}", http.StatusUnsupportedMediaType)
+	expect("", "", http.StatusOK)
+}
@@ -8,7 +8,16 @@ import (
 
 // Struct CORSHandler provides CORS support. It can automatically use an instance of
 // Router to provide accurate responses to an OPTION preflight request, and supports
-// restricting which headers are allowed in input and output.
+// restricting which headers are allowed in input and output. If no router is available,
+// preflight requests are answered with the static list of methods in AllowedMethods.
+//
+// Routes can override AllowedOrigins through the router's AllowOrigins method.
+//
+// CORSHandler answers every OPTIONS request with a 204 No Content status, except those for
+// paths that have an explicit OPTIONS route on its router (see Router.OPTIONS): for those, all
+// the CORS preflight headers (including Access-Control-Allow-Methods) are set, but the status
+// and body are left to the route's handles, which must write them.
+// The router's HandleOPTIONS setting has no effect on paths that CORSHandler answers.
 //
 // CORSHandler conforms to the bowtie.MiddlewareProvided interface.
 //
@@ -16,8 +25,14 @@ import (
 type CORSHandler struct {
 	router         *Router
 	AllowedOrigins []string
+	AllowedMethods []string
 	AllowedHeaders []string
 	ExposedHeaders []string
+	// AllowCredentialsFor, if set, is called with the Origin header of each request (which is
+	// empty if the client didn't send one) and determines whether the
+	// Access-Control-Allow-Credentials header is sent. If it is nil, credentials are allowed for
+	// every origin
+	AllowCredentialsFor func(origin string) bool
 }
 
 func (h *CORSHandler) handle(c bowtie.Context, next func()) {
@@ -27,11 +42,18 @@ func (h *CORSHandler) handle(c bowtie.Context, next func()) {
 	header := res.Header()
 
 	origin := req.Header.Get("Origin")
+	allowedOrigins := h.AllowedOrigins
 
-	if len(h.AllowedOrigins) > 0 {
+	if h.router != nil {
+		if origins, ok := h.router.allowedOrigins(req.URL.Path); ok {
+			allowedOrigins = origins
+		}
+	}
+
+	if len(allowedOrigins) > 0 {
 		found := false
 
-		for _, o := range h.AllowedOrigins {
+		for _, o := range allowedOrigins {
 			if o == origin {
 				found = true
 				break
@@ -44,15 +66,26 @@ func (h *CORSHandler) handle(c bowtie.Context, next func()) {
 		}
 	}
 
+	if h.AllowCredentialsFor == nil || h.AllowCredentialsFor(origin) {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
 	if origin == "" {
 		origin = "*"
 	}
 
-	header.Set("Access-Control-Allow-Credentials", "true")
 	header.Set("Access-Control-Allow-Origin", origin)
 
 	if len(h.AllowedHeaders) > 0 {
-		header.Set("Access-Control-Allow-Headers", strings.Join(h.AllowedHeaders, ", "))
+		allowed := h.AllowedHeaders
+
+		if requested := req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			allowed = h.matchHeaders(requested)
+		}
+
+		if len(allowed) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(allowed, ", "))
+		}
 	}
 
 	if len(h.ExposedHeaders) > 0 {
@@ -60,12 +93,47 @@ func (h *CORSHandler) handle(c bowtie.Context, next func()) {
 	}
 
 	if req.Method == "OPTIONS" {
-		header.Set("Access-Control-Allow-Methods", strings.Join(h.router.GetSupportedMethods(req.URL.Path), ", "))
+		methods := h.AllowedMethods
+		explicit := h.router != nil && h.router.hasRoute("OPTIONS", req.URL.Path)
+
+		if h.router != nil {
+			methods = h.router.GetSupportedMethods(req.URL.Path)
+		}
+
+		if explicit {
+			methods = append(methods, "OPTIONS")
+		}
+
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if explicit {
+			return
+		}
 
 		res.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// matchHeaders returns the allowed headers that appear in `requested`, a comma-separated list
+// taken from a preflight request. Header names are compared case-insensitively, since browsers
+// usually send them in lowercase.
+func (h *CORSHandler) matchHeaders(requested string) []string {
+	result := []string{}
+
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+
+		for _, allowed := range h.AllowedHeaders {
+			if strings.EqualFold(name, allowed) {
+				result = append(result, allowed)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
 // SetDefaults sets a basic set of defaults. Allows any origin and exposes commonly-used headers both
 // in input and output
 func (c *CORSHandler) SetDefaults() {
@@ -82,11 +150,13 @@ func (h *CORSHandler) ContextFactory() bowtie.ContextFactory {
 }
 
 // NewCORSHandler creates a new CORS Handler that uses `router` to determine which HTTP methods
-// are acceptable for a given route.
+// are acceptable for a given route. `router` can be nil, in which case the handler's
+// AllowedMethods are used instead.
 func NewCORSHandler(router *Router) *CORSHandler {
 	return &CORSHandler{
 		router:         router,
 		AllowedOrigins: []string{},
+		AllowedMethods: []string{},
 		AllowedHeaders: []string{},
 		ExposedHeaders: []string{},
 	}
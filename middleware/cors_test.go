@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSRequestedHeadersCaseInsensitive(t *testing.T) {
+	r := NewRouter()
+
+	r.POST("/test", func(c bowtie.Context) {})
+
+	cors := NewCORSHandler(r)
+
+	cors.SetDefaults()
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(cors)
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/test", nil)
+	req.Header.Set("Access-Control-Request-Headers", "content-type, AUTHORIZATION, x-unknown")
+
+	s.ServeHTTP(w, req)
+
+	if allowed := w.Header().Get("Access-Control-Allow-Headers"); allowed != "Content-Type, Authorization" {
+		t.Errorf("Unexpected allowed headers: %s", allowed)
+	}
+}
+
+func TestCORSWithoutRouter(t *testing.T) {
+	cors := NewCORSHandler(nil)
+
+	cors.AllowedMethods = []string{"GET", "PUT"}
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(cors)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "/anything", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNoContent, w.Code)
+	}
+
+	if allowed := w.Header().Get("Access-Control-Allow-Methods"); allowed != "GET, PUT" {
+		t.Errorf("Unexpected allowed methods: %s", allowed)
+	}
+}
+
+func TestCORSPerRouteOrigins(t *testing.T) {
+	r := NewRouter()
+
+	handler := func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	}
+
+	r.GET("/public/items", handler)
+	r.POST("/admin/items", handler)
+	r.GET("/items", handler)
+
+	r.AllowOrigins("/public")
+	r.AllowOrigins("/admin", "https://admin.example.com")
+
+	cors := NewCORSHandler(r)
+
+	cors.AllowedOrigins = []string{"https://app.example.com"}
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(cors)
+	s.AddMiddlewareProvider(r)
+
+	expect := func(method, path, origin string, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s %s from %s, got %d instead", status, method, path, origin, w.Code)
+		}
+	}
+
+	expect("GET", "/public/items", "https://anywhere.example.com", http.StatusOK)
+	expect("POST", "/admin/items", "https://admin.example.com", http.StatusOK)
+	expect("POST", "/admin/items", "https://app.example.com", http.StatusForbidden)
+	expect("OPTIONS", "/admin/items", "https://anywhere.example.com", http.StatusForbidden)
+	expect("GET", "/items", "https://app.example.com", http.StatusOK)
+	expect("GET", "/items", "https://anywhere.example.com", http.StatusForbidden)
+}
+
+func TestCORSAllowCredentialsFor(t *testing.T) {
+	cors := NewCORSHandler(nil)
+
+	cors.AllowedOrigins = []string{"https://app.example.com", "https://partner.example.net"}
+	cors.AllowCredentialsFor = func(origin string) bool {
+		return origin == "https://app.example.com"
+	}
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(cors)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	expect := func(origin, credentials string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", origin)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("Expected %s to be allowed, got %d instead", origin, w.Code)
+		}
+
+		if c := w.Header().Get("Access-Control-Allow-Credentials"); c != credentials {
+			t.Errorf("Expected credentials header %q for %s, got %q instead", credentials, origin, c)
+		}
+	}
+
+	expect("https://app.example.com", "true")
+	expect("https://partner.example.net", "")
+
+	cors.AllowedOrigins = []string{}
+	cors.AllowCredentialsFor = func(origin string) bool {
+		return origin != "" && origin != "*"
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if c := w.Header().Get("Access-Control-Allow-Credentials"); c != "" {
+		t.Errorf("Expected no credentials header for a request without an origin, got %q instead", c)
+	}
+}
+
+func TestCORSExplicitOPTIONSRoute(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/items", func(c bowtie.Context) {})
+	r.OPTIONS("/schema", func(c bowtie.Context) {
+		c.Response().WriteString("schema")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(NewCORSHandler(r))
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int, body, methods string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", "https://example.com")
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status || w.Body.String() != body {
+			t.Errorf("Unexpected response for OPTIONS %s: %d %s", path, w.Code, w.Body.String())
+		}
+
+		if w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+			t.Errorf("Missing CORS headers for OPTIONS %s: %#v", path, w.Header())
+		}
+
+		if w.Header().Get("Access-Control-Allow-Methods") != methods {
+			t.Errorf("Expected methods %q for OPTIONS %s, got %q instead", methods, path, w.Header().Get("Access-Control-Allow-Methods"))
+		}
+	}
+
+	expect("/items", http.StatusNoContent, "", "GET")
+	expect("/schema", http.StatusOK, "schema", "OPTIONS")
+}
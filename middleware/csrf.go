@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+)
+
+// CSRFTokenKey holds the CSRF token for the current request, so that handlers can embed it
+// into the forms they render
+var CSRFTokenKey = bowtie.GenerateContextKey()
+
+// Struct CSRFOptions configures the CSRF middleware. Empty fields are replaced with sensible
+// defaults.
+type CSRFOptions struct {
+	CookieName string // The name of the cookie that holds the token. Defaults to `csrf_token`
+	HeaderName string // The header checked on unsafe requests. Defaults to `X-CSRF-Token`
+	FormField  string // The form field checked if the header is missing. Defaults to `csrf_token`
+	Path       string // The path of the token cookie. Defaults to `/`
+	Secure     bool   // Whether the token cookie should only be sent over HTTPS
+}
+
+// CSRF returns a middleware that protects against cross-site request forgery using the
+// double-submit cookie pattern. On safe requests (GET, HEAD, OPTIONS, and TRACE), it makes
+// sure that the client has a random token cookie; on all other requests, the same token must
+// also be supplied in a header or form field, or the request fails with a 403 error.
+//
+// The current token is stored in the context under CSRFTokenKey.
+func CSRF(opts CSRFOptions) bowtie.Middleware {
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+
+	if opts.FormField == "" {
+		opts.FormField = "csrf_token"
+	}
+
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+
+	return func(c bowtie.Context, next func()) {
+		req := c.Request()
+
+		token := ""
+
+		if cookie, err := req.Cookie(opts.CookieName); err == nil {
+			token = cookie.Value
+		}
+
+		switch req.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			if token == "" {
+				var err error
+
+				if token, err = newCSRFToken(); err != nil {
+					c.Response().AddError(err)
+					return
+				}
+
+				http.SetCookie(c.Response(), &http.Cookie{
+					Name:     opts.CookieName,
+					Value:    token,
+					Path:     opts.Path,
+					Secure:   opts.Secure,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+
+		default:
+			supplied := req.Header.Get(opts.HeaderName)
+
+			if supplied == "" {
+				supplied = req.PostFormValue(opts.FormField)
+			}
+
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(supplied)) != 1 {
+				c.Response().AddError(bowtie.NewError(http.StatusForbidden, "Invalid CSRF token"))
+				return
+			}
+		}
+
+		c.Set(CSRFTokenKey, token)
+	}
+}
+
+// newCSRFToken returns a new random token
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(CSRF(CSRFOptions{}))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) != 1 || cookies[0].Name != "csrf_token" || cookies[0].Value == "" {
+		t.Fatalf("Expected a CSRF token cookie, got %#v instead", cookies)
+	}
+
+	token := cookies[0].Value
+
+	expect := func(description, header string, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", nil)
+
+		req.AddCookie(&http.Cookie{Name: "csrf_token", Value: token})
+
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d instead", status, description, w.Code)
+		}
+	}
+
+	expect("a valid token", token, http.StatusOK)
+	expect("a missing token", "", http.StatusForbidden)
+	expect("a mismatched token", token+"x", http.StatusForbidden)
+}
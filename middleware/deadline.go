@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineHeaderKey holds the name of the header used by DeadlinePropagation
+var DeadlineHeaderKey = bowtie.GenerateContextKey()
+
+// DeadlinePropagation returns a middleware that propagates timeout budgets across services
+// using `header`, whose value is the number of milliseconds left to the caller.
+//
+// If an incoming request carries the header, the middleware applies the corresponding deadline
+// to the request, so that it is reported by the context's RemainingTime(). Handlers can then
+// call PropagateDeadline to pass the remaining budget on to downstream requests.
+func DeadlinePropagation(header string) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		c.Set(DeadlineHeaderKey, header)
+
+		if ms, err := strconv.ParseInt(c.Request().Header.Get(header), 10, 64); err == nil && ms >= 0 {
+			ctx, cancel := context.WithTimeout(c.StdContext(), time.Duration(ms)*time.Millisecond)
+			defer cancel()
+
+			c.WithStdContext(ctx)
+		}
+
+		next()
+	}
+}
+
+// PropagateDeadline sets the deadline header configured by DeadlinePropagation on `downstream`
+// to the number of milliseconds left before the current request's deadline. It does nothing if
+// the request has no deadline or the middleware hasn't been installed.
+func PropagateDeadline(c bowtie.Context, downstream *http.Request) {
+	header, ok := c.Get(DeadlineHeaderKey).(string)
+
+	if !ok {
+		return
+	}
+
+	if remaining, ok := c.RemainingTime(); ok {
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		downstream.Header.Set(header, strconv.FormatInt(int64(remaining/time.Millisecond), 10))
+	}
+}
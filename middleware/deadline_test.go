@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeadlinePropagation(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(DeadlinePropagation("X-Timeout-Ms"))
+
+	var first, second time.Duration
+	var outgoing *http.Request
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		first, _ = c.RemainingTime()
+
+		time.Sleep(20 * time.Millisecond)
+
+		second, _ = c.RemainingTime()
+
+		outgoing, _ = http.NewRequest("GET", "http://example.com/", nil)
+
+		PropagateDeadline(c, outgoing)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Timeout-Ms", "1000")
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if first <= 0 || first > time.Second {
+		t.Errorf("Unexpected remaining budget: %v", first)
+	}
+
+	if second >= first {
+		t.Errorf("Expected remaining budget to decrease, got %v and then %v", first, second)
+	}
+
+	ms, err := strconv.ParseInt(outgoing.Header.Get("X-Timeout-Ms"), 10, 64)
+
+	if err != nil || ms <= 0 || ms >= 1000 {
+		t.Errorf("Unexpected propagated deadline header: %s", outgoing.Header.Get("X-Timeout-Ms"))
+	}
+}
+
+func TestDeadlinePropagationFakeClock(t *testing.T) {
+	bowtie.DefaultClock = bowtie.NewFakeClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { bowtie.DefaultClock = bowtie.RealClock }()
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(DeadlinePropagation("X-Timeout-Ms"))
+
+	var remaining time.Duration
+	var ctxErr error
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		remaining, _ = c.RemainingTime()
+		ctxErr = c.StdContext().Err()
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Timeout-Ms", "60000")
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if ctxErr != nil {
+		t.Errorf("Unexpected context error: %s", ctxErr)
+	}
+
+	if remaining <= 59*time.Second || remaining > time.Minute {
+		t.Errorf("Unexpected remaining budget: %v", remaining)
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"time"
+)
+
+// Deprecated returns a handle that marks the responses of a route as deprecated by setting the
+// `Deprecation: true` header and a `Sunset` header (RFC 8594) that announces the time after
+// which the route will stop working. Place it in front of the route's handles:
+//
+//  r.GET("/v1/users", middleware.Deprecated(sunset), listUsers)
+//
+// To deprecate a whole set of routes, pass it to Router.Use instead. If `sunset` is the zero
+// time, only the Deprecation header is set.
+func Deprecated(sunset time.Time) Handle {
+	return func(c bowtie.Context) {
+		header := c.Response().Header()
+
+		header.Set("Deprecation", "true")
+
+		if !sunset.IsZero() {
+			header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+	}
+}
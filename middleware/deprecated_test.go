@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeprecated(t *testing.T) {
+	r := NewRouter()
+
+	sunset := time.Date(2030, 6, 30, 23, 59, 59, 0, time.FixedZone("EST", -5*60*60))
+
+	r.GET("/old", Deprecated(sunset), func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/old", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	if d := w.Header().Get("Deprecation"); d != "true" {
+		t.Errorf("Expected Deprecation header to be true, got %q instead", d)
+	}
+
+	if s := w.Header().Get("Sunset"); s != "Mon, 01 Jul 2030 04:59:59 GMT" {
+		t.Errorf("Unexpected Sunset header: %q", s)
+	}
+}
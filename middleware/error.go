@@ -1,27 +1,80 @@
 package middleware
 
 import (
+	"encoding/json"
 	"github.com/mtabini/go-bowtie"
+	"net/http"
 )
 
 // ErrorReporter is a middleware that safely handles error reporting
 // by outputting the errors that have accumulated in the context's response
 // writer. It computes the status of a request from the maximum response
 // status of all the errors (if any are present).
+//
+// If any of the errors is a bowtie.ProblemError, the first one is output on its
+// own as an RFC 7807 problem object.
+//
+// If the response is buffered (see bowtie.Server.BufferResponses), any body written
+// before the errors occurred is discarded, so that only the errors are sent.
+//
+// If no errors have been recorded but the response has an error status (for example,
+// because a handler called WriteHeader(404) directly), and neither a Content-Type nor any
+// body has been written, a minimal error is synthesized from the status so that the client
+// still gets a body.
+//
+// Each error is passed through the response's error transformer (see
+// bowtie.Server.ErrorTransformer) before it is output.
+//
+// Errors added after a successful status has been sent to the client (for example, because
+// a handler panicked halfway through a response) are not output, since they would be appended
+// to a body that the client takes to be successful; they can still be logged.
 func ErrorReporter(c bowtie.Context, next func()) {
 	next()
 
 	res := c.Response()
 
+	if res.StatusCommitted() && res.Status() < 400 {
+		return
+	}
+
 	errs := res.Errors()
+
+	if len(errs) == 0 && res.Status() >= 400 && res.Header().Get("Content-Type") == "" && res.BytesWritten() == 0 && len(res.BufferedBody()) == 0 {
+		errs = []bowtie.Error{bowtie.NewError(res.Status(), "%s", http.StatusText(res.Status()))}
+	}
+
+	// Errors() returns the writer's own list, which loggers still need to see untransformed
+	errs = append([]bowtie.Error(nil), errs...)
+
+	for index, err := range errs {
+		errs[index] = res.TransformError(err)
+	}
+
 	outErrs := []bowtie.Error{}
 
+	if len(errs) > 0 {
+		res.DiscardBody()
+	}
+
+	for _, err := range errs {
+		if problem, ok := err.(*bowtie.ProblemError); ok {
+			if p, err := json.Marshal(problem); err == nil {
+				res.Header().Set("Content-Type", bowtie.ProblemContentType)
+				res.WriteErrorBody(p)
+			}
+
+			return
+		}
+	}
+
 	if len(errs) > 0 {
 		maxStatus := 0
 
 		for _, err := range errs {
 			if err.StatusCode() < 500 {
 				outErrs = append(outErrs, err)
+			} else if err.StatusCode() > maxStatus {
+				maxStatus = err.StatusCode()
 			}
 		}
 
@@ -29,6 +82,13 @@ func ErrorReporter(c bowtie.Context, next func()) {
 			outErrs = append(outErrs, bowtie.NewError(500, "A server error has occurred"))
 		}
 
-		c.Response().WriteJSON(outErrs)
+		p, err := json.Marshal(outErrs)
+
+		if err != nil {
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteErrorBody(p)
 	}
 }
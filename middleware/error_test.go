@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/mtabini/go-bowtie"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorReporterWithResponseTransformer(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.ResponseTransformer = func(data interface{}) interface{} {
+		return map[string]interface{}{"data": data}
+	}
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		if c.Request().URL.Path == "/error" {
+			c.Response().AddError(bowtie.NewError(400, "Bad request"))
+			return
+		}
+
+		c.Response().WriteJSON(map[string]interface{}{"test": 123})
+	})
+
+	expect := func(path, output string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Body.String() != output {
+			t.Errorf("Unexpected output for %s: %s", path, w.Body.String())
+		}
+	}
+
+	expect("/", `{"data":{"test":123}}`)
+	expect("/error", `[{"message":"Bad request","statusCode":400}]`)
+}
+
+func TestErrorReporterProblemDetails(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		e := bowtie.NewProblemError(http.StatusForbidden, "", "Your account has no credit left")
+
+		e.Type = "https://example.com/probs/out-of-credit"
+		e.Instance = "/account/12345"
+
+		c.Response().AddError(e)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	res := w.Result()
+
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d instead", http.StatusForbidden, res.StatusCode)
+	}
+
+	if ct := res.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	expected := `{"detail":"Your account has no credit left","instance":"/account/12345","status":403,"title":"Forbidden","type":"https://example.com/probs/out-of-credit"}`
+
+	if w.Body.String() != expected {
+		t.Errorf("Unexpected problem body: %s", w.Body.String())
+	}
+}
+
+func TestErrorReporterBufferedDiscardsBody(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.BufferResponses = true
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("partial output")
+		c.Response().AddError(errors.New("Something broke"))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	res := w.Result()
+
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d instead", http.StatusInternalServerError, res.StatusCode)
+	}
+
+	if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	if w.Body.String() != `[{"message":"An server error has occurred.","statusCode":500}]` {
+		t.Errorf("Unexpected output: %s", w.Body.String())
+	}
+}
+
+func TestErrorReporterStatusWithoutErrors(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteHeader(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNotFound, w.Code)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	if w.Body.String() != `[{"message":"Not Found","statusCode":404}]` {
+		t.Errorf("Unexpected output: %s", w.Body.String())
+	}
+}
+
+func TestErrorReporterErrorTransformer(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.ErrorTransformer = func(err bowtie.Error) bowtie.Error {
+		if err.StatusCode() == http.StatusConflict {
+			return bowtie.NewError(err.StatusCode(), "[redacted]")
+		}
+
+		return err
+	}
+
+	var logged []bowtie.Error
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		next()
+
+		logged = c.Response().Errors()
+	})
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().AddError(bowtie.NewError(http.StatusConflict, "Duplicate key users_email_idx"))
+		c.Response().AddError(bowtie.NewError(http.StatusBadRequest, "Missing name"))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Body.String() != `[{"message":"[redacted]","statusCode":409},{"message":"Missing name","statusCode":400}]` {
+		t.Errorf("Unexpected output: %s", w.Body.String())
+	}
+
+	if len(logged) != 2 || logged[0].Message() != "Duplicate key users_email_idx" {
+		t.Errorf("Expected Errors() to return the original errors, got %v instead", logged)
+	}
+}
+
+func TestErrorReporterStatusWithBody(t *testing.T) {
+	r := NewRouter()
+
+	r.SetNotFound(func(c bowtie.Context) {
+		c.Response().WriteString("<h1>Branded</h1>")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddlewareProvider(r)
+
+	ss := httptest.NewServer(s)
+	defer ss.Close()
+
+	res, err := http.Get(ss.URL + "/missing")
+
+	if err != nil {
+		t.Fatalf("Unable to run test server: %s", err)
+	}
+
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode != http.StatusNotFound || string(body) != "<h1>Branded</h1>" {
+		t.Errorf("Unexpected response: %d %s", res.StatusCode, body)
+	}
+}
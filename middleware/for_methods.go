@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+)
+
+// ForMethods returns a middleware that runs `mw` only for requests made with one of `methods`,
+// and simply moves on to the next middleware otherwise. For example, to require authentication
+// only for requests that modify data:
+//
+//  s.AddMiddleware(middleware.ForMethods([]string{"POST", "PUT", "PATCH", "DELETE"}, auth))
+func ForMethods(methods []string, mw bowtie.Middleware) bowtie.Middleware {
+	set := map[string]bool{}
+
+	for _, method := range methods {
+		set[method] = true
+	}
+
+	return func(c bowtie.Context, next func()) {
+		if set[c.Method()] {
+			mw(c, next)
+			return
+		}
+
+		next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForMethods(t *testing.T) {
+	s := bowtie.NewServer()
+
+	ran := false
+
+	s.AddMiddleware(ForMethods([]string{"POST", "PUT"}, func(c bowtie.Context, next func()) {
+		ran = true
+	}))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	expect := func(method string, expected bool) {
+		ran = false
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/", nil)
+
+		s.ServeHTTP(w, req)
+
+		if ran != expected {
+			t.Errorf("Expected inner middleware to run for %s: %v, got %v instead", method, expected, ran)
+		}
+
+		if w.Body.String() != "ok" {
+			t.Errorf("Expected the chain to continue for %s, got %q instead", method, w.Body.String())
+		}
+	}
+
+	expect("POST", true)
+	expect("PUT", true)
+	expect("GET", false)
+}
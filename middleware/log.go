@@ -7,6 +7,7 @@ import (
 	"github.com/mtabini/go-bowtie"
 	"github.com/mtabini/go-bunyan"
 	"log"
+	"sort"
 	"time"
 )
 
@@ -23,18 +24,52 @@ import (
 type Logger func(c bowtie.Context)
 
 // MakePlaintextLogger logs requests to standard output using this space-limited simple format:
-// RemoteAddress Method URL Status RunningTime
+// RemoteAddress Method URL Status RunningTime BytesRead [key=value...]
+//
+// The trailing key/value pairs are the request's tags (see bowtie.Context.Tag), sorted by key
 func MakePlaintextLogger() Logger {
 	return func(c bowtie.Context) {
 		req := c.Request()
 		res := c.Response()
 
-		log.Printf("%s %s %s %d %f", req.RemoteAddr, req.Method, req.URL, res.Status(), float64(c.GetRunningTime())/float64(time.Second))
+		log.Printf("%s %s %s %d %f %d%s", c.RemoteAddr(), req.Method, req.URL, res.Status(), float64(c.GetRunningTime())/float64(time.Second), req.BytesRead(), formatTags(c.Tags()))
+	}
+}
+
+// formatTags formats `tags` as a sequence of space-prefixed key=value pairs, sorted by key
+func formatTags(tags map[string]interface{}) string {
+	keys := make([]string, 0, len(tags))
+
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	result := ""
+
+	for _, key := range keys {
+		result += fmt.Sprintf(" %s=%v", key, tags[key])
+	}
+
+	return result
+}
+
+// MakeCombinedLogger logs requests to standard output using a format modelled on the
+// Combined Log Format: RemoteAddress - - "RequestLine" Status - "Referer" "UserAgent" BytesRead
+func MakeCombinedLogger() Logger {
+	return func(c bowtie.Context) {
+		req := c.Request()
+		res := c.Response()
+
+		log.Printf("%s - - %q %d - %q %q %d", c.RemoteAddr(), req.RequestLine(), res.Status(), req.Referer(), req.UserAgent(), req.BytesRead())
 	}
 }
 
 // BunyanLogger logs requests using a Bunyan logger. See https://github.com/mtabini/go-bunyan
-// for more information
+// for more information. Errors are logged using their private representation, which includes
+// the chain of errors they wrap. The time spent in each of the request's phases so far (see
+// bowtie.Context.Phases) is logged as the `phases` record
 func MakeBunyanLogger(logger *bunyan.Logger) Logger {
 	return func(c bowtie.Context) {
 		req := c.Request()
@@ -47,6 +82,20 @@ func MakeBunyanLogger(logger *bunyan.Logger) Logger {
 
 		e.SetCompletedIn(fmt.Sprintf("%v", c.GetRunningTime()))
 
+		for key, value := range c.Tags() {
+			e.SetRecord(key, value)
+		}
+
+		if phases := c.Phases(); len(phases) > 0 {
+			durations := map[string]string{}
+
+			for name, d := range phases {
+				durations[name] = d.String()
+			}
+
+			e.SetRecord("phases", durations)
+		}
+
 		errs := res.Errors()
 
 		if len(errs) > 0 {
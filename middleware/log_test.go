@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/mtabini/go-bowtie"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaintextLoggerBytesRead(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stderr)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(NewLogger(MakePlaintextLogger()))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteStringOrError(c.Request().StringBody())
+	})
+
+	req, _ := http.NewRequest("POST", "/test", strings.NewReader("Hello, world"))
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if line := strings.TrimSpace(out.String()); !strings.HasSuffix(line, " 12") {
+		t.Errorf("Expected 12 bytes read to be logged, got %q instead", line)
+	}
+}
+
+func TestPlaintextLoggerTags(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stderr)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(NewLogger(MakePlaintextLogger()))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Tag("user_id", 42)
+		c.Tag("plan", "pro")
+
+		c.Response().WriteString("ok")
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if line := strings.TrimSpace(out.String()); !strings.HasSuffix(line, " 0 plan=pro user_id=42") {
+		t.Errorf("Expected tags to be logged, got %q instead", line)
+	}
+}
+
+func TestPlaintextLoggerFakeClock(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stderr)
+
+	clock := bowtie.NewFakeClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	bowtie.DefaultClock = clock
+	defer func() { bowtie.DefaultClock = bowtie.RealClock }()
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(NewLogger(MakePlaintextLogger()))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		clock.Advance(250 * time.Millisecond)
+
+		c.Response().WriteString("ok")
+	})
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if line := strings.TrimSpace(out.String()); !strings.HasSuffix(line, " 200 0.250000 0") {
+		t.Errorf("Expected a running time of 0.25s to be logged, got %q instead", line)
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+)
+
+// MaxURILength returns a middleware that rejects requests whose URI (path and query) is
+// longer than `n` bytes with a 414 Request-URI Too Long error. Add it before the router so
+// that overlong URLs never reach your handlers.
+func MaxURILength(n int) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		if len(c.Request().URL.RequestURI()) > n {
+			c.Response().AddError(bowtie.NewError(http.StatusRequestURITooLong, "Request URI too long"))
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxURILength(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(MaxURILength(32))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("ok")
+	})
+
+	expect := func(uri string, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", uri, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d instead", status, uri, w.Code)
+		}
+	}
+
+	expect("/short?x=1", http.StatusOK)
+	expect("/long?x="+strings.Repeat("a", 32), http.StatusRequestURITooLong)
+}
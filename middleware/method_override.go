@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MethodOverrideHeader is the header from which MethodOverride reads the intended method
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// MethodOverrideField is the form field from which MethodOverride reads the intended method
+// if the header is missing
+const MethodOverrideField = "_method"
+
+// methodOverrides lists the methods that a POST request can be turned into
+var methodOverrides = map[string]bool{
+	"PUT":    true,
+	"PATCH":  true,
+	"DELETE": true,
+}
+
+// MethodOverride returns a middleware that allows clients that can only send GET and POST
+// requests to use other methods. The method of a POST request is replaced with the value of
+// the X-HTTP-Method-Override header or, for URL-encoded forms, of the `_method` field.
+// Only PUT, PATCH, and DELETE are accepted; any other override results in a 400 Bad Request
+// error. Add it before the router.
+func MethodOverride() bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		req := c.Request()
+
+		if req.Method != "POST" {
+			return
+		}
+
+		method := req.Header.Get(MethodOverrideHeader)
+
+		if method == "" {
+			if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+				method = req.PostFormValue(MethodOverrideField)
+			}
+		}
+
+		if method == "" {
+			return
+		}
+
+		method = strings.ToUpper(method)
+
+		if !methodOverrides[method] {
+			c.Response().AddError(bowtie.NewError(http.StatusBadRequest, "Method override %s is not allowed", method))
+			return
+		}
+
+		req.Method = method
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMethodOverride(t *testing.T) {
+	r := NewRouter()
+
+	handler := func(c bowtie.Context) {
+		c.Response().WriteString(c.Method())
+	}
+
+	r.POST("/items", handler)
+	r.PUT("/items", handler)
+	r.DELETE("/items", handler)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(MethodOverride())
+	s.AddMiddlewareProvider(r)
+
+	expect := func(req *http.Request, status int, body string) {
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d, got %d instead", status, w.Code)
+		}
+
+		if body != "" && w.Body.String() != body {
+			t.Errorf("Expected body %q, got %q instead", body, w.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/items", nil)
+	expect(req, http.StatusOK, "POST")
+
+	req, _ = http.NewRequest("POST", "/items", nil)
+	req.Header.Set(MethodOverrideHeader, "put")
+	expect(req, http.StatusOK, "PUT")
+
+	req, _ = http.NewRequest("POST", "/items", strings.NewReader(url.Values{"_method": {"DELETE"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expect(req, http.StatusOK, "DELETE")
+
+	req, _ = http.NewRequest("POST", "/items", nil)
+	req.Header.Set(MethodOverrideHeader, "CONNECT")
+	expect(req, http.StatusBadRequest, "")
+
+	req, _ = http.NewRequest("GET", "/items", nil)
+	req.Header.Set(MethodOverrideHeader, "DELETE")
+	expect(req, http.StatusMethodNotAllowed, "")
+}
@@ -1,23 +1,89 @@
 package middleware
 
 import (
+	"bytes"
 	"github.com/mtabini/go-bowtie"
+	"html/template"
 	"net/http"
+	"sync/atomic"
 )
 
-// Recovery returns a middleware that recovers from any panics and writes a 500 if there was one.
-// While Martini is in development mode, Recovery will also output the panic as HTML.
+var panicCount int64
+
+// PanicCount returns the number of panics that Recovery has recovered from since the
+// process started, across all servers. It is safe to call concurrently and is meant to be
+// scraped for alerting. The panics of a single server are also counted in its Stats.
+func PanicCount() int64 {
+	return atomic.LoadInt64(&panicCount)
+}
+
+// Recovery is a middleware that recovers from any panics and adds a 500 error to the response
+// if there was one. The error's stack trace is captured for logging, but never output to the
+// client.
+//
+// If the handler that panicked had already sent a status (for example, a 201), the status is
+// left alone, so that the response stays consistent: the error is still recorded for loggers,
+// but no further output is written to the body.
 //
 // Borrowed from https://github.com/go-martini/martini/blob/master/recovery.go
 func Recovery(c bowtie.Context, next func()) {
-	defer func() {
-		if err := recover(); err != nil {
-			e := bowtie.NewError(http.StatusInternalServerError, "panic: %#v", err)
-			e.CaptureStackTrace()
+	NewRecovery(false)(c, next)
+}
+
+// NewRecovery returns a middleware that recovers from panics. If `debug` is false, it behaves
+// exactly like Recovery. If `debug` is true, it instead writes an HTML page that contains the
+// panic and its stack trace to the response, which is handy during local development.
+//
+// Because the debug page exposes the internals of your application, `debug` must never be
+// enabled in production.
+func NewRecovery(debug bool) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		defer func() {
+			if err := recover(); err != nil {
+				atomic.AddInt64(&panicCount, 1)
+				c.RecordPanic()
+
+				e := bowtie.NewErrorFromPanic(err)
+				e.CaptureStackTrace()
+
+				if debug && !c.Response().StatusCommitted() {
+					writeDebugPanic(c, e)
+					return
+				}
+
+				c.Response().AddError(e)
+			}
+		}()
+
+		next()
+	}
+}
+
+var debugPanicTemplate = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Panic</title></head>
+<body>
+<h1>{{.Message}}</h1>
+<pre>{{range .StackTrace}}{{.Func}}
+	{{.Path}}:{{.Line}}
+		{{.Source}}
+{{end}}</pre>
+</body>
+</html>
+`))
+
+// writeDebugPanic outputs an HTML page that describes the panic in `e`
+func writeDebugPanic(c bowtie.Context, e bowtie.Error) {
+	out := &bytes.Buffer{}
+
+	if err := debugPanicTemplate.Execute(out, e); err != nil {
+		c.Response().AddError(e)
+		return
+	}
 
-			c.Response().AddError(e)
-		}
-	}()
+	res := c.Response()
 
-	next()
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(http.StatusInternalServerError)
+	res.WriteErrorBody(out.Bytes())
 }
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryPanicCount(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Recovery)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		if c.Request().URL.Path == "/panic" {
+			panic("test")
+		}
+	})
+
+	before := PanicCount()
+
+	for _, path := range []string{"/panic", "/", "/panic", "/panic"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+	}
+
+	if count := PanicCount() - before; count != 3 {
+		t.Errorf("Expected 3 panics, got %d instead", count)
+	}
+
+	if count := s.Stats().Panics; count != 3 {
+		t.Errorf("Expected the server to count 3 panics, got %d instead", count)
+	}
+}
+
+func TestRecoveryDebugMode(t *testing.T) {
+	expect := func(debug bool, contentType string, contains string) {
+		s := bowtie.NewServer()
+
+		s.AddMiddleware(ErrorReporter)
+		s.AddMiddleware(NewRecovery(debug))
+		s.AddMiddleware(func(c bowtie.Context, next func()) {
+			panic("<secret>")
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d instead", http.StatusInternalServerError, w.Code)
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != contentType {
+			t.Errorf("Unexpected Content-Type header in debug mode %v: %s", debug, ct)
+		}
+
+		if !strings.Contains(w.Body.String(), contains) {
+			t.Errorf("Unexpected output in debug mode %v: %s", debug, w.Body.String())
+		}
+
+		if debug != strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("Panic details unexpectedly %s in debug mode %v", map[bool]string{true: "missing", false: "leaked"}[debug], debug)
+		}
+	}
+
+	expect(true, "text/html; charset=utf-8", "panic: &lt;secret&gt;")
+	expect(false, "application/json", `"statusCode":500`)
+}
+
+func TestRecoveryAfterStatusCommitted(t *testing.T) {
+	s := bowtie.NewServer()
+
+	var status int
+	var errs []bowtie.Error
+
+	s.OnResponse(func(c bowtie.Context) {
+		status = c.Response().Status()
+		errs = c.Response().Errors()
+	})
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(Recovery)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().Header().Set("Location", "/items/1")
+		c.Response().WriteHeader(http.StatusCreated)
+
+		panic("half-way through")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/items", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated || status != http.StatusCreated {
+		t.Errorf("Expected status %d to be preserved, got %d (reported as %d) instead", http.StatusCreated, w.Code, status)
+	}
+
+	if w.Header().Get("Location") != "/items/1" || w.Header().Get("Content-Type") != "" {
+		t.Errorf("Unexpected headers: %#v", w.Header())
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %s instead", w.Body.String())
+	}
+
+	if len(errs) != 1 || errs[0].StatusCode() != http.StatusInternalServerError {
+		t.Errorf("Expected the panic to be recorded privately, got %#v instead", errs)
+	}
+}
+
+func TestRecoveryAfterImplicitStatus(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddleware(Recovery)
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		c.Response().WriteString("partial")
+
+		panic("half-way through")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d to be preserved, got %d instead", http.StatusOK, w.Code)
+	}
+
+	if w.Body.String() != "partial" {
+		t.Errorf("Expected no errors to be appended to the body, got %s instead", w.Body.String())
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+)
+
+// RequestIDKey holds the ID assigned to the current request by RequestID
+var RequestIDKey = bowtie.GenerateContextKey()
+
+// RequestID returns a middleware that assigns an ID to each request, stores it in the context
+// under RequestIDKey, and echoes it back to the client in `header`. If the incoming request
+// already carries `header` (for example, because it was set by a proxy), its value is reused;
+// otherwise, a new ID is obtained from the context's NewID().
+func RequestID(header string) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		id := c.Request().Header.Get(header)
+
+		if id == "" {
+			id = c.NewID()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Response().Header().Set(header, id)
+	}
+}
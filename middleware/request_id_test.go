@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDWithInjectedGenerator(t *testing.T) {
+	s := bowtie.NewServer()
+
+	s.IDGenerator = func() string {
+		return "fixed-id"
+	}
+
+	var id interface{}
+
+	s.AddMiddleware(RequestID("X-Request-Id"))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		id = c.Get(RequestIDKey)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if id != "fixed-id" {
+		t.Errorf("Unexpected request ID in context: %#v", id)
+	}
+
+	if header := w.Header().Get("X-Request-Id"); header != "fixed-id" {
+		t.Errorf("Unexpected request ID header: %s", header)
+	}
+
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "upstream-id")
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if id != "upstream-id" {
+		t.Errorf("Expected the upstream request ID to be reused, got %#v instead", id)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"regexp"
+	"strings"
+)
+
+// Rewrite returns a middleware that internally rewrites the request's path before it reaches
+// the router, without sending a redirect to the client. Add it before the router.
+//
+// If `from` starts with `^`, it is compiled as a regular expression and every match is replaced
+// with `to`, which can refer to submatches using `$1`, `${name}`, and so on. Otherwise, `from`
+// is treated as a path prefix, which is replaced with `to` when it matches a whole number of
+// path segments:
+//
+//  s.AddMiddleware(middleware.Rewrite("/v1", ""))                        // /v1/foo => /foo
+//  s.AddMiddleware(middleware.Rewrite(`^/users/(\d+)$`, "/people/$1"))   // /users/1 => /people/1
+func Rewrite(from, to string) bowtie.Middleware {
+	var rewrite func(path string) string
+
+	if strings.HasPrefix(from, "^") {
+		re := regexp.MustCompile(from)
+
+		rewrite = func(path string) string {
+			return re.ReplaceAllString(path, to)
+		}
+	} else {
+		prefix := strings.TrimSuffix(from, "/")
+
+		rewrite = func(path string) string {
+			if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+				return path
+			}
+
+			return to + path[len(prefix):]
+		}
+	}
+
+	return func(c bowtie.Context, next func()) {
+		u := c.Request().URL
+
+		if path := rewrite(u.Path); path != u.Path {
+			if path == "" || path[0] != '/' {
+				path = "/" + path
+			}
+
+			u.Path = path
+			u.RawPath = ""
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/foo", func(c bowtie.Context) {
+		c.Response().WriteString("foo")
+	})
+
+	r.GET("/people/:id", func(c bowtie.Context) {
+		c.Response().WriteString("person " + GetParams(c).ByName("id"))
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Rewrite("/v1", ""))
+	s.AddMiddleware(Rewrite(`^/users/(\d+)$`, "/people/$1"))
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int, body string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d instead", status, path, w.Code)
+		}
+
+		if body != "" && w.Body.String() != body {
+			t.Errorf("Expected body %q for %s, got %q instead", body, path, w.Body.String())
+		}
+	}
+
+	expect("/foo", http.StatusOK, "foo")
+	expect("/v1/foo", http.StatusOK, "foo")
+	expect("/v10/foo", http.StatusNotFound, "")
+	expect("/users/42", http.StatusOK, "person 42")
+	expect("/v1/users/42", http.StatusOK, "person 42")
+	expect("/users/abc", http.StatusNotFound, "")
+}
@@ -4,8 +4,15 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"github.com/mtabini/go-bowtie"
+	"io/fs"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Handle is a function that can be registered to a route to handle HTTP
@@ -18,8 +25,64 @@ var _ bowtie.MiddlewareProvider = &Router{}
 
 var RouterParamsKey = bowtie.GenerateContextKey()
 
+// GetParams returns the parameters extracted by the router from the request's path. It is safe to
+// call even if the router's context factory hasn't been registered (for example, because the
+// router was added with AddMiddleware(r.Serve) rather than AddMiddlewareProvider(r)), in which
+// case it returns an empty list if the router hasn't matched the request.
+func GetParams(c bowtie.Context) Params {
+	if ps, ok := c.Get(RouterParamsKey).(Params); ok {
+		return ps
+	}
+
+	return Params{}
+}
+
+// RouterHandlerNamesKey holds the names of the handlers wrapped with Named that have
+// been executed for the current request, in the order in which they ran
+var RouterHandlerNamesKey = bowtie.GenerateContextKey()
+
+// RouterMatchedKey holds a boolean that indicates whether the request matched one of the
+// router's routes. Metrics middleware can use it to bucket unmatched requests together
+var RouterMatchedKey = bowtie.GenerateContextKey()
+
+// RouterRouteKey holds the route (for example, /users/:id) that matched the current request
+var RouterRouteKey = bowtie.GenerateContextKey()
+
+// PhaseRouting and PhaseHandler are the names of the phases (see bowtie.Context.Phases) during
+// which the router looks up the handles for a request and runs them, respectively
+const (
+	PhaseRouting = "routing"
+	PhaseHandler = "handler"
+)
+
+// GetRoute returns the route (for example, /users/:id) that matched the current request, or an
+// empty string if the router hasn't matched it. Unlike the request's path, the route is
+// suitable for naming metrics and traces, because it doesn't vary with the parameters
+func GetRoute(c bowtie.Context) string {
+	route, _ := c.Get(RouterRouteKey).(string)
+
+	return route
+}
+
 func RouterContextFactory(context bowtie.Context) {
 	context.Set(RouterParamsKey, Params{})
+	context.Set(RouterRouteKey, "")
+	context.Set(RouterMatchedKey, false)
+	context.Set(RouterGroupKey, "")
+	context.Set(RouterHandlerNamesKey, []string{})
+}
+
+// Named wraps a handler so that its name is recorded into the context under
+// RouterHandlerNamesKey when the router executes it. This is useful when tracing
+// which handler in a long chain did what.
+func Named(name string, h Handle) Handle {
+	return func(c bowtie.Context) {
+		names, _ := c.Get(RouterHandlerNamesKey).([]string)
+
+		c.Set(RouterHandlerNamesKey, append(names, name))
+
+		h(c)
+	}
 }
 
 // Original Copyright 2013 Julien Schmidt. All rights reserved.
@@ -69,7 +132,7 @@ func RouterContextFactory(context bowtie.Context) {
 // There are two ways to retrieve the value of a parameter; if c is the context
 // passed to the handler:
 //
-//  ps := c.(RouterContext).Params()
+//  ps := middleware.GetParams(c)
 //
 //  // by the name of the parameter
 //  user := ps.ByName("user") // defined by :user or *user
@@ -78,7 +141,13 @@ func RouterContextFactory(context bowtie.Context) {
 //  thirdKey   := ps[2].Key   // the name of the 3rd parameter
 //  thirdValue := ps[2].Value // the value of the 3rd parameter
 type Router struct {
-	trees map[string]*node
+	trees    map[string]*node
+	fallback HandleList
+	notFound HandleList
+	names    map[string]string
+	groups   map[string]string
+	uses     []routeMiddleware
+	origins  []routeOrigins
 
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
@@ -97,14 +166,51 @@ type Router struct {
 	// For example /FOO and /..//Foo could be redirected to /foo.
 	// RedirectTrailingSlash is independent of this option.
 	RedirectFixedPath bool
+
+	// The status code used when redirecting GET requests. Defaults to 301.
+	RedirectPermanentCode int
+
+	// The status code used when redirecting requests made with any method other
+	// than GET. Defaults to 307, which preserves the request method.
+	RedirectTemporaryCode int
+
+	// If set, HandlerWrapper is applied to every handle when it is registered through Handle
+	// (and its shortcuts), Use, Fallback, or SetNotFound, so that a single hook can instrument all of them,
+	// for example to start a tracing span around each handle. Set it before registering
+	// any routes; handles registered earlier are not wrapped.
+	HandlerWrapper func(Handle) Handle
+
+	// If enabled, the router checks whether a request that can't be routed matches a route
+	// registered under other methods and, if so, responds with a 405 Method Not Allowed error
+	// and an Allow header that lists those methods, instead of a 404. Enabled by NewRouter.
+	HandleMethodNotAllowed bool
+
+	// If enabled, the router answers OPTIONS requests for paths that don't have an explicit
+	// OPTIONS route with a 204 No Content status and an Allow header that lists the methods
+	// registered for the path. Note that a CORSHandler installed ahead of the router answers
+	// OPTIONS requests itself (again, unless the path has an explicit OPTIONS route), so this
+	// only applies to routers that are used without one.
+	HandleOPTIONS bool
+
+	// If enabled, the router responds to every request with a 503 Service Unavailable
+	// error, except for those whose path is listed in MaintenanceAllowedPaths (for
+	// example, a health check), which are dispatched normally.
+	Maintenance bool
+
+	// The paths that are still served while Maintenance is enabled.
+	MaintenanceAllowedPaths []string
 }
 
 // New returns a new initialized Router.
-// Path auto-correction, including trailing slashes, is enabled by default.
+// Path auto-correction, including trailing slashes, is enabled by default, as is
+// the 405 Method Not Allowed response for paths registered under other methods.
 func NewRouter() *Router {
 	return &Router{
-		RedirectTrailingSlash: true,
-		RedirectFixedPath:     true,
+		RedirectTrailingSlash:  true,
+		RedirectFixedPath:      true,
+		RedirectPermanentCode:  http.StatusMovedPermanently,
+		RedirectTemporaryCode:  http.StatusTemporaryRedirect,
+		HandleMethodNotAllowed: true,
 	}
 }
 
@@ -138,6 +244,82 @@ func (r *Router) DELETE(path string, handles ...Handle) {
 	r.Handle("DELETE", path, handles)
 }
 
+// OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle). Explicit OPTIONS routes take
+// precedence over both HandleOPTIONS and CORSHandler
+func (r *Router) OPTIONS(path string, handles ...Handle) {
+	r.Handle("OPTIONS", path, handles)
+}
+
+// ServeFile registers a GET handle at `path` that serves the file at `filepath` using
+// http.ServeFile, which takes care of the Content-Type, Last-Modified and range headers. If the
+// file doesn't exist, a 404 error is added to the response instead.
+func (r *Router) ServeFile(path, filepath string) {
+	r.GET(path, func(c bowtie.Context) {
+		if info, err := os.Stat(filepath); err != nil || info.IsDir() {
+			c.Response().AddError(bowtie.NewError(http.StatusNotFound, "Document not found"))
+			return
+		}
+
+		http.ServeFile(c.Response(), c.Request().Request, filepath)
+	})
+}
+
+// ServeFiles serves files from the given file system root. The path must end with "/*filepath";
+// files are then served from the local path /defined/root/dir/*filepath. For example, if root is
+// "/etc" and *filepath is "passwd", the local file "/etc/passwd" is served. Internally, an
+// http.FileServer is used, which takes care of the Content-Type, Last-Modified and range
+// headers, so http.NotFound is used instead of the router's 404 error. To use the operating
+// system's file system implementation, use http.Dir:
+//
+//  router.ServeFiles("/src/*filepath", http.Dir("/var/www"))
+func (r *Router) ServeFiles(path string, root http.FileSystem) {
+	r.serveFiles(path, root, nil)
+}
+
+// ServeEmbedded serves the files in `fsys`, which is typically an embed.FS, like ServeFiles.
+// Because embedded files have no modification time, each file is given a strong ETag computed
+// from its contents, so that clients can still revalidate their cached copies.
+func (r *Router) ServeEmbedded(path string, fsys fs.FS) {
+	etags := sync.Map{}
+
+	r.serveFiles(path, http.FS(fsys), func(c bowtie.Context, name string) {
+		etag, ok := etags.Load(name)
+
+		if !ok {
+			data, err := fs.ReadFile(fsys, strings.TrimPrefix(name, "/"))
+
+			if err != nil {
+				return
+			}
+
+			etag, _ = etags.LoadOrStore(name, fmt.Sprintf(`"%x"`, sha256.Sum256(data)))
+		}
+
+		c.Response().Header().Set("ETag", etag.(string))
+	})
+}
+
+// serveFiles registers a GET handle at `path` that serves files from `root`, calling `before`,
+// if set, with the name of the requested file first
+func (r *Router) serveFiles(path string, root http.FileSystem, before func(c bowtie.Context, name string)) {
+	if !strings.HasSuffix(path, "/*filepath") {
+		panic("path must end with /*filepath in path '" + path + "'")
+	}
+
+	fileServer := http.FileServer(root)
+
+	r.GET(path, func(c bowtie.Context) {
+		req := c.Request()
+		req.URL.Path = GetParams(c).ByName("filepath")
+
+		if before != nil {
+			before(c, req.URL.Path)
+		}
+
+		fileServer.ServeHTTP(c.Response(), req.Request)
+	})
+}
+
 // Handle registers a new request handle with the given path and method.
 //
 // For GET, POST, PUT, PATCH and DELETE requests the respective shortcut
@@ -147,6 +329,12 @@ func (r *Router) DELETE(path string, handles ...Handle) {
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
 func (r *Router) Handle(method, path string, handles HandleList) {
+	r.handle(method, path, r.wrap(handles))
+}
+
+// handle adds `handles` to the tree for `method` as they are, without applying the
+// router's HandlerWrapper
+func (r *Router) handle(method, path string, handles HandleList) {
 	if path[0] != '/' {
 		panic("path must begin with '/'")
 	}
@@ -164,14 +352,210 @@ func (r *Router) Handle(method, path string, handles HandleList) {
 	root.addRoute(path, handles)
 }
 
+// wrap applies the router's HandlerWrapper, if any, to each of `handles`
+func (r *Router) wrap(handles HandleList) HandleList {
+	if r.HandlerWrapper == nil {
+		return handles
+	}
+
+	result := make(HandleList, len(handles))
+
+	for index, handle := range handles {
+		result[index] = r.HandlerWrapper(handle)
+	}
+
+	return result
+}
+
+// Mount registers all the routes of `sub` on r under `prefix`. For example, if sub handles
+// /users, mounting it under /api/v1 makes r handle /api/v1/users with the same handles.
+// Because the routes are fully qualified on r, GetSupportedMethods (and, therefore, CORS
+// preflight requests) reports them correctly.
+//
+// The handles registered on sub with Use, the origins registered with AllowOrigins, and the
+// names of sub's routes are carried over as well, under the same prefix. A route name that is
+// already registered on r causes a panic, as it would with HandleNamed.
+//
+// The routes are copied at the time Mount is called; routes added to sub afterwards are not
+// reflected on r. The handles have already been wrapped by sub's HandlerWrapper, if any, and
+// are not wrapped again by r's.
+func (r *Router) Mount(prefix string, sub *Router) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	for method, root := range sub.trees {
+		root.walk("", func(path string, handles HandleList) {
+			r.handle(method, prefix+path, handles)
+
+			if group, ok := sub.groups[method+" "+path]; ok {
+				r.setGroup(method, prefix+path, prefix+group)
+			}
+		})
+	}
+
+	for _, use := range sub.uses {
+		r.uses = append(r.uses, routeMiddleware{
+			prefix:  prefix + use.prefix,
+			handles: use.handles,
+		})
+	}
+
+	for _, o := range sub.origins {
+		r.origins = append(r.origins, routeOrigins{
+			prefix:  prefix + o.prefix,
+			origins: o.origins,
+		})
+	}
+
+	for name, path := range sub.names {
+		if _, ok := r.names[name]; ok {
+			panic("a route named '" + name + "' has already been registered")
+		}
+
+		if r.names == nil {
+			r.names = map[string]string{}
+		}
+
+		r.names[name] = prefix + path
+	}
+}
+
+// Struct RouteInfo describes a route registered on a router
+type RouteInfo struct {
+	Method  string // The HTTP method of the route
+	Path    string // The route's pattern, including :param and *catchall segments
+	Handles int    // The number of handles registered for the route
+}
+
+// Routes returns all the routes registered on r, sorted by path and method, for example to
+// generate documentation. Handles added with Use or Fallback are not counted.
+func (r *Router) Routes() []RouteInfo {
+	result := []RouteInfo{}
+
+	for method, root := range r.trees {
+		root.walk("", func(path string, handles HandleList) {
+			result = append(result, RouteInfo{
+				Method:  method,
+				Path:    path,
+				Handles: len(handles),
+			})
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
+		}
+
+		return result[i].Method < result[j].Method
+	})
+
+	return result
+}
+
+// routeMiddleware associates a list of handles with a path prefix
+type routeMiddleware struct {
+	prefix  string
+	handles HandleList
+}
+
+// matches returns true if `path` is equal to the middleware's prefix or is below it
+func (m routeMiddleware) matches(path string) bool {
+	return matchesPrefix(m.prefix, path)
+}
+
+// matchesPrefix returns true if `path` is equal to `prefix` or is below it
+func matchesPrefix(prefix, path string) bool {
+	if prefix == "" || prefix == path {
+		return true
+	}
+
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// Use registers handles that run before the handles of any matched route whose path is
+// `pattern` or is below it. For example, Use("/admin", auth) runs auth for /admin and
+// /admin/users, but not for /administrators. Handles registered by Use run in the order
+// in which they were added, and can interrupt the chain by writing to the output.
+func (r *Router) Use(pattern string, handles ...Handle) {
+	r.uses = append(r.uses, routeMiddleware{
+		prefix:  strings.TrimSuffix(pattern, "/"),
+		handles: r.wrap(handles),
+	})
+}
+
+// routeOrigins associates a list of CORS origins with a path prefix
+type routeOrigins struct {
+	prefix  string
+	origins []string
+}
+
+// AllowOrigins overrides the AllowedOrigins of a CORSHandler that uses this router for requests
+// whose path is `pattern` or is below it, with the same matching rules as Use. If several
+// patterns match a request, the longest one wins. Passing no origins allows any origin. For
+// example:
+//
+//  r.AllowOrigins("/public")                              // any origin
+//  r.AllowOrigins("/admin", "https://admin.example.com")  // just the admin console
+func (r *Router) AllowOrigins(pattern string, origins ...string) {
+	r.origins = append(r.origins, routeOrigins{
+		prefix:  strings.TrimSuffix(pattern, "/"),
+		origins: origins,
+	})
+}
+
+// allowedOrigins returns the CORS origins registered with AllowOrigins for `path`. The second
+// return value is false if no pattern matches `path`
+func (r *Router) allowedOrigins(path string) ([]string, bool) {
+	var result *routeOrigins
+
+	for index, o := range r.origins {
+		if matchesPrefix(o.prefix, path) && (result == nil || len(o.prefix) > len(result.prefix)) {
+			result = &r.origins[index]
+		}
+	}
+
+	if result == nil {
+		return nil, false
+	}
+
+	return result.origins, true
+}
+
+// Fallback registers a chain of handles that is executed in place of the default 404 error
+// when no route matches the request. This can be used, for example, to reverse-proxy
+// unknown paths to a legacy application.
+func (r *Router) Fallback(handles ...Handle) {
+	r.fallback = r.wrap(handles)
+}
+
+// SetNotFound registers a chain of handles that is executed in place of the default 404 error
+// when no route matches the request and no fallback has been registered, so that, for example,
+// a branded page can be rendered. The response's status is set to 404 before the handles run,
+// but they can change it; like the handles of a route, they stop running as soon as one of
+// them writes to the response.
+func (r *Router) SetNotFound(handles ...Handle) {
+	r.notFound = r.wrap(handles)
+}
+
 var methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
 
+// hasRoute returns true if a route registered with `method` matches `path`
+func (r *Router) hasRoute(method, path string) bool {
+	if root := r.trees[method]; root != nil {
+		if handles, _, _, _ := root.getValue(path); handles != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *Router) GetSupportedMethods(path string) []string {
 	result := []string{}
 
 	for _, method := range methods {
 		if root := r.trees[method]; root != nil {
-			if handles, _, _ := root.getValue(path); handles != nil {
+			if handles, _, _, _ := root.getValue(path); handles != nil {
 				result = append(result, method)
 			}
 		}
@@ -184,32 +568,43 @@ func (r *Router) GetSupportedMethods(path string) []string {
 func (r *Router) Serve(c bowtie.Context, next func()) {
 	req := c.Request()
 
+	stopRouting := c.StartPhase(PhaseRouting)
+	defer stopRouting()
+
+	if r.Maintenance && !r.allowedInMaintenance(req.URL.Path) {
+		c.Response().AddError(bowtie.NewError(http.StatusServiceUnavailable, "Service unavailable due to maintenance"))
+		return
+	}
+
 	if root := r.trees[req.Method]; root != nil {
 		path := req.URL.Path
 
-		if handles, ps, tsr := root.getValue(path); handles != nil {
+		if handles, ps, route, tsr := root.getValue(path); handles != nil {
 			c.Set(RouterParamsKey, ps)
+			c.Set(RouterMatchedKey, true)
+			c.Set(RouterRouteKey, route)
+			c.Set(RouterGroupKey, r.groups[req.Method+" "+route])
 
-			index := 0
+			if len(r.uses) > 0 {
+				chain := HandleList{}
 
-			for index < len(handles) {
-				handles[index](c)
-
-				if c.Response().Written() {
-					return
+				for _, use := range r.uses {
+					if use.matches(path) {
+						chain = append(chain, use.handles...)
+					}
 				}
 
-				index += 1
+				handles = append(chain, handles...)
 			}
 
+			stopRouting()
+			defer c.StartPhase(PhaseHandler)()
+
+			runHandles(c, handles)
+
 			return
 		} else if req.Method != "CONNECT" && path != "/" {
-			code := 301 // Permanent redirect, request with GET method
-			if req.Method != "GET" {
-				// Temporary redirect, request with same method
-				// As of Go 1.3, Go does not support status code 308.
-				code = 307
-			}
+			code := r.redirectCode(req.Method)
 
 			if tsr && r.RedirectTrailingSlash {
 				if len(path) > 1 && path[len(path)-1] == '/' {
@@ -236,9 +631,90 @@ func (r *Router) Serve(c bowtie.Context, next func()) {
 		}
 	}
 
+	c.Set(RouterMatchedKey, false)
+
+	if req.Method == "OPTIONS" && r.HandleOPTIONS {
+		if allowed := r.GetSupportedMethods(req.URL.Path); len(allowed) > 0 {
+			c.Response().Header().Set("Allow", strings.Join(append(allowed, "OPTIONS"), ", "))
+			c.Response().WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	if r.HandleMethodNotAllowed {
+		if allowed := r.GetSupportedMethods(req.URL.Path); len(allowed) > 0 {
+			c.Response().Header().Set("Allow", strings.Join(allowed, ", "))
+			c.Response().AddError(bowtie.NewError(http.StatusMethodNotAllowed, "Method not allowed"))
+			return
+		}
+	}
+
+	if r.fallback != nil {
+		stopRouting()
+		defer c.StartPhase(PhaseHandler)()
+
+		runHandles(c, r.fallback)
+		return
+	}
+
+	if r.notFound != nil {
+		stopRouting()
+		defer c.StartPhase(PhaseHandler)()
+
+		c.Response().SetStatus(http.StatusNotFound)
+		runHandles(c, r.notFound)
+		return
+	}
+
 	c.Response().AddError(bowtie.NewError(http.StatusNotFound, "Document not found"))
 }
 
+// allowedInMaintenance returns true if `path` is one of the router's MaintenanceAllowedPaths
+func (r *Router) allowedInMaintenance(path string) bool {
+	for _, allowed := range r.MaintenanceAllowedPaths {
+		if path == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runHandles executes handles in sequence until one of them writes to the output
+func runHandles(c bowtie.Context, handles HandleList) {
+	index := 0
+
+	for index < len(handles) {
+		handles[index](c)
+
+		if c.Response().Written() {
+			return
+		}
+
+		index += 1
+	}
+}
+
+// redirectCode returns the status code used to redirect a request made with `method`,
+// falling back to 301 and 307 if the router's codes haven't been set
+func (r *Router) redirectCode(method string) int {
+	if method == "GET" {
+		// Permanent redirect, request with GET method
+		if r.RedirectPermanentCode != 0 {
+			return r.RedirectPermanentCode
+		}
+
+		return http.StatusMovedPermanently
+	}
+
+	// Temporary redirect, request with same method
+	if r.RedirectTemporaryCode != 0 {
+		return r.RedirectTemporaryCode
+	}
+
+	return http.StatusTemporaryRedirect
+}
+
 // MiddlewareProvider interface
 
 func (r *Router) Middleware() bowtie.Middleware {
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"strings"
+)
+
+// RouterGroupKey holds the prefix of the route group that matched the current request, if any
+var RouterGroupKey = bowtie.GenerateContextKey()
+
+// GetGroup returns the prefix of the route group that matched the current request, or an
+// empty string if the request was matched by a route that doesn't belong to a group
+func GetGroup(c bowtie.Context) string {
+	group, _ := c.Get(RouterGroupKey).(string)
+
+	return group
+}
+
+// Struct RouterGroup registers routes on a router under a common prefix, running a shared
+// chain of handles (for example, authentication) before the handles of each route. Groups
+// only exist at registration time: their routes are added to the router like any other, so
+// matching performance is unaffected. The router remembers which group each route belongs
+// to, and handlers (and middleware) can find it out by calling GetGroup:
+//
+//  api := router.Group("/api/v1", authenticate)
+//  admin := api.Group("/admin", requireAdmin)
+//
+//  admin.GET("/users", listUsers) // GET /api/v1/admin/users runs authenticate, requireAdmin, listUsers
+type RouterGroup struct {
+	router  *Router
+	prefix  string
+	handles HandleList
+}
+
+// Group creates a new route group whose routes are registered on r under `prefix`, preceded
+// by `handles`
+func (r *Router) Group(prefix string, handles ...Handle) *RouterGroup {
+	return &RouterGroup{
+		router:  r,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		handles: handles,
+	}
+}
+
+// Group creates a nested route group. Its prefix is appended to that of g, and its routes run
+// the handles of g before `handles`
+func (g *RouterGroup) Group(prefix string, handles ...Handle) *RouterGroup {
+	chain := append(HandleList{}, g.handles...)
+
+	return &RouterGroup{
+		router:  g.router,
+		prefix:  g.prefix + strings.TrimSuffix(prefix, "/"),
+		handles: append(chain, handles...),
+	}
+}
+
+// Prefix returns the group's prefix
+func (g *RouterGroup) Prefix() string {
+	return g.prefix
+}
+
+// GET is a shortcut for group.Handle("GET", path, handle)
+func (g *RouterGroup) GET(path string, handles ...Handle) {
+	g.Handle("GET", path, handles)
+}
+
+// HEAD is a shortcut for group.Handle("HEAD", path, handle)
+func (g *RouterGroup) HEAD(path string, handles ...Handle) {
+	g.Handle("HEAD", path, handles)
+}
+
+// POST is a shortcut for group.Handle("POST", path, handle)
+func (g *RouterGroup) POST(path string, handles ...Handle) {
+	g.Handle("POST", path, handles)
+}
+
+// PUT is a shortcut for group.Handle("PUT", path, handle)
+func (g *RouterGroup) PUT(path string, handles ...Handle) {
+	g.Handle("PUT", path, handles)
+}
+
+// PATCH is a shortcut for group.Handle("PATCH", path, handle)
+func (g *RouterGroup) PATCH(path string, handles ...Handle) {
+	g.Handle("PATCH", path, handles)
+}
+
+// DELETE is a shortcut for group.Handle("DELETE", path, handle)
+func (g *RouterGroup) DELETE(path string, handles ...Handle) {
+	g.Handle("DELETE", path, handles)
+}
+
+// Handle registers a new request handle with the given method and with the group's prefix
+// prepended to `path`. The group's shared handles run before `handles`
+func (g *RouterGroup) Handle(method, path string, handles HandleList) {
+	chain := append(HandleList{}, g.handles...)
+
+	g.router.Handle(method, g.prefix+path, append(chain, handles...))
+	g.router.setGroup(method, g.prefix+path, g.prefix)
+}
+
+// setGroup records that the route registered with `method` and `path` belongs to the group
+// whose prefix is `prefix`, so that Serve can report it through GetGroup
+func (r *Router) setGroup(method, path, prefix string) {
+	if r.groups == nil {
+		r.groups = map[string]string{}
+	}
+
+	r.groups[method+" "+path] = prefix
+}
@@ -21,3 +21,17 @@ func (ps Params) ByName(name string) string {
 	}
 	return ""
 }
+
+// Map returns the parameters as a map of keys to values, for example for templating or
+// logging. If a key appears more than once, the first value is kept, like ByName does.
+func (ps Params) Map() map[string]string {
+	result := make(map[string]string, len(ps))
+
+	for i := range ps {
+		if _, ok := result[ps[i].Key]; !ok {
+			result[ps[i].Key] = ps[i].Value
+		}
+	}
+
+	return result
+}
@@ -5,7 +5,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestRouter(t *testing.T) {
@@ -40,3 +44,894 @@ func TestRouter(t *testing.T) {
 		t.Errorf("Unexpected response from test server: %s", output)
 	}
 }
+
+func TestRouterRedirectCodes(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/test", func(c bowtie.Context) {})
+	r.POST("/test", func(c bowtie.Context) {})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(method string, code int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/test/", nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != code {
+			t.Errorf("Expected status %d for %s redirect, got %d instead", code, method, w.Code)
+		}
+	}
+
+	expect("GET", http.StatusMovedPermanently)
+	expect("POST", http.StatusTemporaryRedirect)
+
+	r.RedirectPermanentCode = http.StatusPermanentRedirect
+	r.RedirectTemporaryCode = http.StatusFound
+
+	expect("GET", http.StatusPermanentRedirect)
+	expect("POST", http.StatusFound)
+}
+
+func TestRouterNamedHandlers(t *testing.T) {
+	r := NewRouter()
+
+	var names []string
+
+	r.GET(
+		"/test",
+		Named("first", func(c bowtie.Context) {}),
+		func(c bowtie.Context) {},
+		Named("last", func(c bowtie.Context) {
+			names = c.Get(RouterHandlerNamesKey).([]string)
+
+			c.Response().WriteString("ok")
+		}),
+	)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	req, _ := http.NewRequest("GET", "/test", nil)
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(names) != 2 || names[0] != "first" || names[1] != "last" {
+		t.Errorf("Unexpected handler names recorded: %#v", names)
+	}
+}
+
+func TestRouterFallback(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/test", func(c bowtie.Context) {
+		c.Response().WriteString("matched")
+	})
+
+	var ran []string
+
+	r.Fallback(
+		func(c bowtie.Context) {
+			ran = append(ran, "first")
+		},
+		func(c bowtie.Context) {
+			ran = append(ran, "second")
+
+			c.Response().WriteHeader(http.StatusBadGateway)
+		},
+	)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/unknown/path", nil)
+
+	s.ServeHTTP(w, req)
+
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Errorf("Unexpected fallback chain execution: %#v", ran)
+	}
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected status %d, got %d instead", http.StatusBadGateway, w.Code)
+	}
+
+	ran = nil
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/test", nil)
+
+	s.ServeHTTP(w, req)
+
+	if len(ran) != 0 || w.Body.String() != "matched" {
+		t.Errorf("Fallback unexpectedly ran for a matched route: %#v", ran)
+	}
+}
+
+func TestRouterMountSupportedMethods(t *testing.T) {
+	sub := NewRouter()
+
+	sub.GET("/users", func(c bowtie.Context) {})
+	sub.POST("/users", func(c bowtie.Context) {})
+	sub.DELETE("/users/:id", func(c bowtie.Context) {})
+
+	r := NewRouter()
+
+	r.GET("/health", func(c bowtie.Context) {})
+	r.Mount("/api/v1", sub)
+
+	cors := NewCORSHandler(r)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(cors)
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path, methods string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if allowed := w.Header().Get("Access-Control-Allow-Methods"); allowed != methods {
+			t.Errorf("Expected methods %q for %s, got %q instead", methods, path, allowed)
+		}
+	}
+
+	expect("/api/v1/users", "GET, POST")
+	expect("/api/v1/users/123", "DELETE")
+	expect("/health", "GET")
+	expect("/users", "")
+}
+
+func TestRouterMountWrapsOnce(t *testing.T) {
+	wrapped := 0
+
+	wrapper := func(h Handle) Handle {
+		return func(c bowtie.Context) {
+			wrapped += 1
+
+			h(c)
+		}
+	}
+
+	sub := NewRouter()
+
+	sub.HandlerWrapper = wrapper
+	sub.GET("/users", func(c bowtie.Context) {})
+
+	r := NewRouter()
+
+	r.HandlerWrapper = wrapper
+	r.Mount("/api/v1", sub)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/users", nil)
+
+	s.ServeHTTP(w, req)
+
+	if wrapped != 1 {
+		t.Errorf("Expected the mounted handle to be wrapped once, got %d instead", wrapped)
+	}
+}
+
+func TestRouterMountUse(t *testing.T) {
+	sub := NewRouter()
+
+	sub.Use("/admin", func(c bowtie.Context) {
+		c.Respond(http.StatusUnauthorized, nil)
+	})
+
+	sub.AllowOrigins("/admin", "https://admin.example.com")
+
+	sub.GETNamed("admin-users", "/admin/users", func(c bowtie.Context) {
+		c.Response().WriteString("protected")
+	})
+
+	r := NewRouter()
+
+	r.Mount("/api", sub)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/users", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized || w.Body.String() == "protected" {
+		t.Errorf("Expected the mounted Use handle to run, got %d %s instead", w.Code, w.Body.String())
+	}
+
+	if origins, ok := r.allowedOrigins("/api/admin/users"); !ok || len(origins) != 1 || origins[0] != "https://admin.example.com" {
+		t.Errorf("Unexpected origins for the mounted path: %v", origins)
+	}
+
+	if url, err := r.URL("admin-users", nil); err != nil || url != "/api/admin/users" {
+		t.Errorf("Unexpected URL for the mounted route: %s (%v)", url, err)
+	}
+}
+
+func TestRouterMatched(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/test", func(c bowtie.Context) {})
+
+	s := bowtie.NewServer()
+
+	var matched interface{}
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		next()
+
+		matched = c.Get(RouterMatchedKey)
+	})
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, expected bool) {
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(httptest.NewRecorder(), req)
+
+		if matched != expected {
+			t.Errorf("Expected matched to be %v for %s, got %#v instead", expected, path, matched)
+		}
+	}
+
+	expect("/test", true)
+	expect("/unknown", false)
+}
+
+func TestRouterAsPlainMiddleware(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/test/:id", func(c bowtie.Context) {
+		c.Response().WriteString("Hello " + GetParams(c).ByName("id"))
+	})
+
+	s := bowtie.NewServer()
+
+	var params Params
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		next()
+
+		params = GetParams(c)
+	})
+	s.AddMiddleware(r.Serve)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test/123", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Body.String() != "Hello 123" {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/unknown", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound || params == nil || len(params) != 0 {
+		t.Errorf("Unexpected result for unmatched route: %d, %#v", w.Code, params)
+	}
+}
+
+func TestRouterUse(t *testing.T) {
+	r := NewRouter()
+
+	ran := []string{}
+
+	r.Use("/admin", func(c bowtie.Context) {
+		ran = append(ran, "auth")
+
+		if c.Request().Header.Get("Authorization") == "" {
+			c.Response().AddError(bowtie.NewError(http.StatusUnauthorized, "Unauthorized"))
+		}
+	})
+
+	handler := func(c bowtie.Context) {
+		ran = append(ran, "handler")
+
+		c.Response().WriteString("ok")
+	}
+
+	r.GET("/admin", handler)
+	r.GET("/admin/users", handler)
+	r.GET("/administrators", handler)
+	r.GET("/public", handler)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int, expected ...string) {
+		ran = []string{}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d instead", status, path, w.Code)
+		}
+
+		if len(ran) != len(expected) {
+			t.Errorf("Unexpected handles for %s: %#v", path, ran)
+			return
+		}
+
+		for index := range expected {
+			if ran[index] != expected[index] {
+				t.Errorf("Unexpected handles for %s: %#v", path, ran)
+			}
+		}
+	}
+
+	expect("/admin", http.StatusUnauthorized, "auth")
+	expect("/admin/users", http.StatusUnauthorized, "auth")
+	expect("/administrators", http.StatusOK, "handler")
+	expect("/public", http.StatusOK, "handler")
+}
+
+func TestRouterServeFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "favicon.ico")
+
+	if err := ioutil.WriteFile(file, []byte("icon data"), 0644); err != nil {
+		t.Fatalf("Unable to create test file: %s", err)
+	}
+
+	r := NewRouter()
+
+	r.ServeFile("/favicon.ico", file)
+	r.ServeFile("/missing.ico", filepath.Join(dir, "missing.ico"))
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/favicon.ico", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "icon data" {
+		t.Errorf("Unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("Last-Modified") == "" {
+		t.Error("Expected a Last-Modified header")
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/missing.ico", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRouterGroup(t *testing.T) {
+	r := NewRouter()
+
+	var group string
+
+	handler := func(c bowtie.Context) {
+		group = GetGroup(c)
+
+		c.Response().WriteString("ok")
+	}
+
+	api := r.Group("/api/v1")
+
+	api.GET("/users", handler)
+	r.GET("/health", handler)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path, expected string) {
+		group = "unset"
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || group != expected {
+			t.Errorf("Expected group %q for %s, got %q (%d) instead", expected, path, group, w.Code)
+		}
+	}
+
+	expect("/api/v1/users", "/api/v1")
+	expect("/health", "")
+}
+
+func TestRouterGroupHandles(t *testing.T) {
+	r := NewRouter()
+
+	var ran []string
+
+	step := func(name string) Handle {
+		return func(c bowtie.Context) {
+			ran = append(ran, name)
+		}
+	}
+
+	api := r.Group("/api/v1/", step("auth"))
+	admin := api.Group("/admin", step("admin"))
+
+	api.GET("/users", step("users"))
+	admin.GET("/stats", step("stats"), func(c bowtie.Context) {
+		c.Response().WriteString(GetGroup(c))
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, steps ...string) {
+		ran = nil
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if strings.Join(ran, ",") != strings.Join(steps, ",") {
+			t.Errorf("Unexpected handles run for %s: %#v", path, ran)
+		}
+	}
+
+	expect("/api/v1/users", "auth", "users")
+	expect("/api/v1/admin/stats", "auth", "admin", "stats")
+
+	if len(api.handles) != 1 {
+		t.Errorf("Nested group unexpectedly changed its parent's handles: %d", len(api.handles))
+	}
+}
+
+func TestRouterGroupMetadata(t *testing.T) {
+	r := NewRouter()
+
+	wrapped := 0
+
+	r.HandlerWrapper = func(h Handle) Handle {
+		wrapped += 1
+
+		return h
+	}
+
+	api := r.Group("/api/v1")
+
+	api.GET("/users", func(c bowtie.Context) {
+		c.Response().WriteString(GetGroup(c))
+	})
+
+	if wrapped != 1 {
+		t.Errorf("Expected the wrapper to be applied to 1 handle, got %d instead", wrapped)
+	}
+
+	routes := r.Routes()
+
+	if len(routes) != 1 || routes[0].Handles != 1 {
+		t.Errorf("Unexpected routes: %#v", routes)
+	}
+
+	parent := NewRouter()
+
+	parent.Mount("/v2", r)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(parent)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v2/api/v1/users", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Body.String() != "/v2/api/v1" {
+		t.Errorf("Expected the mounted group to be /v2/api/v1, got %q instead", w.Body.String())
+	}
+}
+
+func TestRouterHandlerWrapper(t *testing.T) {
+	r := NewRouter()
+
+	calls := 0
+
+	r.HandlerWrapper = func(h Handle) Handle {
+		return func(c bowtie.Context) {
+			calls += 1
+
+			h(c)
+		}
+	}
+
+	r.Use("/", func(c bowtie.Context) {})
+
+	r.GET("/users", func(c bowtie.Context) {}, func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d instead", http.StatusOK, w.Code)
+	}
+
+	if calls != 3 {
+		t.Errorf("Expected the wrapper to run for 3 handles, got %d instead", calls)
+	}
+}
+
+func TestRouterMaintenance(t *testing.T) {
+	r := NewRouter()
+
+	handler := func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	}
+
+	r.GET("/users", handler)
+	r.GET("/healthz", handler)
+
+	r.MaintenanceAllowedPaths = []string{"/healthz"}
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s, got %d instead", status, path, w.Code)
+		}
+	}
+
+	expect("/users", http.StatusOK)
+
+	r.Maintenance = true
+
+	expect("/users", http.StatusServiceUnavailable)
+	expect("/healthz", http.StatusOK)
+
+	r.Maintenance = false
+
+	expect("/users", http.StatusOK)
+}
+
+func TestRouterServeEmbedded(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/site.css": &fstest.MapFile{Data: []byte("body { color: red; }")},
+	}
+
+	r := NewRouter()
+
+	r.ServeEmbedded("/static/*filepath", fsys)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	request := func(path, etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		s.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := request("/static/css/site.css", "")
+
+	if w.Code != http.StatusOK || w.Body.String() != "body { color: red; }" {
+		t.Errorf("Unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/css; charset=utf-8" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	etag := w.Header().Get("ETag")
+
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+
+	if w := request("/static/css/site.css", etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNotModified, w.Code)
+	}
+
+	if w := request("/static/css/missing.css", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRouterPhases(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/slow/:id", func(c bowtie.Context) {
+		time.Sleep(5 * time.Millisecond)
+		c.Response().WriteString("done")
+	})
+
+	var phases map[string]time.Duration
+
+	s := bowtie.NewServer()
+
+	s.OnResponse(func(c bowtie.Context) {
+		phases = c.Phases()
+	})
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/slow/12", nil)
+
+	s.ServeHTTP(w, req)
+
+	routing, handler, total := phases[PhaseRouting], phases[PhaseHandler], phases[bowtie.PhaseMiddleware]
+
+	if routing <= 0 || handler <= 0 || total <= 0 {
+		t.Fatalf("Expected non-zero phases, got %v instead", phases)
+	}
+
+	if handler < 5*time.Millisecond || routing >= handler {
+		t.Errorf("Unexpected routing and handler phases: %v", phases)
+	}
+
+	if total < routing+handler {
+		t.Errorf("Expected the middleware phase to include routing and handler, got %v instead", phases)
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/test", func(c bowtie.Context) {
+		c.Response().WriteString("matched")
+	})
+
+	var ran []string
+
+	r.SetNotFound(
+		func(c bowtie.Context) {
+			ran = append(ran, "first")
+
+			if c.Get(RouterMatchedKey) != false || GetRoute(c) != "" || len(GetParams(c)) != 0 {
+				t.Error("Unexpected router context in NotFound handler")
+			}
+
+			c.Response().Header().Set("Content-Type", "application/json")
+			c.Response().WriteString(`{"error":"nothing here"}`)
+		},
+		func(c bowtie.Context) {
+			ran = append(ran, "second")
+		},
+	)
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int, body string, handlers int) {
+		ran = nil
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status || w.Body.String() != body {
+			t.Errorf("Unexpected response for %s: %d %s", path, w.Code, w.Body.String())
+		}
+
+		if len(ran) != handlers {
+			t.Errorf("Expected %d NotFound handlers to run for %s, got %#v instead", handlers, path, ran)
+		}
+	}
+
+	expect("/unknown", http.StatusNotFound, `{"error":"nothing here"}`, 1)
+	expect("/test", http.StatusOK, "matched", 0)
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	r := NewRouter()
+
+	r.GET("/items/:id", func(c bowtie.Context) {
+		c.Response().WriteString("item")
+	})
+
+	r.DELETE("/items/:id", func(c bowtie.Context) {
+		c.Response().WriteString("deleted")
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(method, path string, status int, allow string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d for %s %s, got %d instead", status, method, path, w.Code)
+		}
+
+		if w.Header().Get("Allow") != allow {
+			t.Errorf("Expected Allow %q for %s %s, got %q instead", allow, method, path, w.Header().Get("Allow"))
+		}
+	}
+
+	expect("GET", "/items/1", http.StatusOK, "")
+	expect("POST", "/items/1", http.StatusMethodNotAllowed, "GET, DELETE")
+	expect("POST", "/unknown", http.StatusNotFound, "")
+
+	r.HandleMethodNotAllowed = false
+
+	expect("POST", "/items/1", http.StatusNotFound, "")
+}
+
+func TestRouterParamsMap(t *testing.T) {
+	r := NewRouter()
+
+	var params map[string]string
+
+	r.GET("/users/:user/posts/:post/*rest", func(c bowtie.Context) {
+		params = GetParams(c).Map()
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/12/posts/34/comments/56", nil)
+
+	s.ServeHTTP(w, req)
+
+	if len(params) != 3 || params["user"] != "12" || params["post"] != "34" || params["rest"] != "/comments/56" {
+		t.Errorf("Unexpected params map: %#v", params)
+	}
+
+	if m := (Params{{"id", "1"}, {"id", "2"}}).Map(); len(m) != 1 || m["id"] != "1" {
+		t.Errorf("Expected the first of duplicate keys to be kept, got %#v instead", m)
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	r := NewRouter()
+	handler := func(c bowtie.Context) {}
+
+	r.GETNamed("user.show", "/users/:id", handler)
+	r.GETNamed("user.post", "/users/:id/posts/:post", handler)
+	r.GETNamed("static", "/static/*filepath", handler)
+
+	expect := func(name string, params map[string]string, expected string) {
+		path, err := r.URL(name, params)
+
+		if err != nil {
+			t.Errorf("Unable to build URL for %s: %s", name, err)
+		}
+
+		if path != expected {
+			t.Errorf("Expected %s for %s, got %s instead", expected, name, path)
+		}
+	}
+
+	expect("user.show", map[string]string{"id": "42"}, "/users/42")
+	expect("user.show", map[string]string{"id": "a b/c"}, "/users/a%20b%2Fc")
+	expect("user.post", map[string]string{"id": "1", "post": "2"}, "/users/1/posts/2")
+	expect("static", map[string]string{"filepath": "/css/site.css"}, "/static/css/site.css")
+
+	if _, err := r.URL("user.post", map[string]string{"id": "1"}); err == nil {
+		t.Error("Expected an error for a missing parameter")
+	}
+
+	if _, err := r.URL("user.delete", nil); err == nil {
+		t.Error("Expected an error for an unknown route")
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	r := NewRouter()
+	handler := func(c bowtie.Context) {}
+
+	r.GET("/users/:id", handler)
+	r.DELETE("/users/:id", handler, handler)
+	r.GET("/users", handler)
+	r.POST("/users", handler)
+	r.GET("/static/*filepath", handler)
+	r.GET("/", handler)
+
+	expected := []RouteInfo{
+		{"GET", "/", 1},
+		{"GET", "/static/*filepath", 1},
+		{"GET", "/users", 1},
+		{"POST", "/users", 1},
+		{"DELETE", "/users/:id", 2},
+		{"GET", "/users/:id", 1},
+	}
+
+	routes := r.Routes()
+
+	if len(routes) != len(expected) {
+		t.Fatalf("Unexpected routes: %#v", routes)
+	}
+
+	for index, route := range routes {
+		if route != expected[index] {
+			t.Errorf("Expected route %#v at position %d, got %#v instead", expected[index], index, route)
+		}
+	}
+}
+
+func TestRouterHandleOPTIONS(t *testing.T) {
+	r := NewRouter()
+	handler := func(c bowtie.Context) {}
+
+	r.GET("/items", handler)
+	r.POST("/items", handler)
+	r.GET("/schema", handler)
+	r.OPTIONS("/schema", func(c bowtie.Context) {
+		c.Response().WriteJSON(map[string]string{"type": "object"})
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddlewareProvider(r)
+
+	expect := func(path string, status int, allow, body string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", path, nil)
+
+		s.ServeHTTP(w, req)
+
+		if w.Code != status || w.Header().Get("Allow") != allow || w.Body.String() != body {
+			t.Errorf("Unexpected response for OPTIONS %s: %d %q %s", path, w.Code, w.Header().Get("Allow"), w.Body.String())
+		}
+	}
+
+	expect("/items", http.StatusMethodNotAllowed, "GET, POST", "")
+
+	r.HandleOPTIONS = true
+
+	expect("/items", http.StatusNoContent, "GET, POST, OPTIONS", "")
+	expect("/schema", http.StatusOK, "", `{"type":"object"}`)
+}
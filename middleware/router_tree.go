@@ -46,6 +46,7 @@ type node struct {
 	indices   []byte
 	children  []*node
 	handle    HandleList
+	route     string
 	priority  uint32
 }
 
@@ -72,6 +73,7 @@ func (n *node) incrementChildPrio(i int) int {
 // addRoute adds a node with the given handle to the path.
 // Not concurrency-safe!
 func (n *node) addRoute(path string, handle HandleList) {
+	fullPath := path
 	n.priority++
 	numParams := countParams(path)
 
@@ -99,6 +101,7 @@ func (n *node) addRoute(path string, handle HandleList) {
 					indices:   n.indices,
 					children:  n.children,
 					handle:    n.handle,
+					route:     n.route,
 					priority:  n.priority - 1,
 				}
 
@@ -113,6 +116,7 @@ func (n *node) addRoute(path string, handle HandleList) {
 				n.indices = []byte{n.path[i]}
 				n.path = path[:i]
 				n.handle = nil
+				n.route = ""
 				n.wildChild = false
 			}
 
@@ -169,7 +173,7 @@ func (n *node) addRoute(path string, handle HandleList) {
 					n.incrementChildPrio(len(n.indices) - 1)
 					n = child
 				}
-				n.insertChild(numParams, path, handle)
+				n.insertChild(numParams, path, fullPath, handle)
 				return
 
 			} else if i == len(path) { // Make node a (in-path) leaf
@@ -177,15 +181,16 @@ func (n *node) addRoute(path string, handle HandleList) {
 					panic("a Handle is already registered for this path")
 				}
 				n.handle = handle
+				n.route = fullPath
 			}
 			return
 		}
 	} else { // Empty tree
-		n.insertChild(numParams, path, handle)
+		n.insertChild(numParams, path, fullPath, handle)
 	}
 }
 
-func (n *node) insertChild(numParams uint8, path string, handle HandleList) {
+func (n *node) insertChild(numParams uint8, path, fullPath string, handle HandleList) {
 	var offset int
 
 	// find prefix until first wildcard (beginning with ':'' or '*'')
@@ -276,6 +281,7 @@ func (n *node) insertChild(numParams uint8, path string, handle HandleList) {
 				nType:     catchAll,
 				maxParams: 1,
 				handle:    handle,
+				route:     fullPath,
 				priority:  1,
 			}
 			n.children = []*node{child}
@@ -287,14 +293,15 @@ func (n *node) insertChild(numParams uint8, path string, handle HandleList) {
 	// insert remaining path part and handle to the leaf
 	n.path = path[offset:]
 	n.handle = handle
+	n.route = fullPath
 }
 
 // Returns the handle registered with the given path (key). The values of
 // wildcards are saved to a map.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
-// given path.
-func (n *node) getValue(path string) (handles HandleList, p Params, tsr bool) {
+// given path. The route with which the handle was registered is also returned.
+func (n *node) getValue(path string) (handles HandleList, p Params, route string, tsr bool) {
 walk: // Outer loop for walking the tree
 	for {
 		if len(path) > len(n.path) {
@@ -354,6 +361,7 @@ walk: // Outer loop for walking the tree
 					}
 
 					if handles = n.handle; handles != nil {
+						route = n.route
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -376,6 +384,7 @@ walk: // Outer loop for walking the tree
 					p[i].Value = path
 
 					handles = n.handle
+					route = n.route
 					return
 
 				default:
@@ -386,6 +395,7 @@ walk: // Outer loop for walking the tree
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
 			if handles = n.handle; handles != nil {
+				route = n.route
 				return
 			}
 
@@ -532,3 +542,17 @@ func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (ciPa
 	}
 	return
 }
+
+// walk calls fn for every handle stored in the tree rooted at n, passing it
+// the full path the handle was registered with.
+func (n *node) walk(prefix string, fn func(path string, handle HandleList)) {
+	path := prefix + n.path
+
+	if n.handle != nil {
+		fn(path, n.handle)
+	}
+
+	for _, child := range n.children {
+		child.walk(path, fn)
+	}
+}
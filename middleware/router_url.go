@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// HandleNamed works like Handle, but also registers the route's path under `name`, so that
+// URLs that point to it can be built with URL. Registering two routes under the same name
+// causes a panic
+func (r *Router) HandleNamed(name, method, path string, handles HandleList) {
+	if _, ok := r.names[name]; ok {
+		panic("a route named '" + name + "' has already been registered")
+	}
+
+	r.Handle(method, path, handles)
+
+	if r.names == nil {
+		r.names = map[string]string{}
+	}
+
+	r.names[name] = path
+}
+
+// GETNamed is a shortcut for router.HandleNamed(name, "GET", path, handle)
+func (r *Router) GETNamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "GET", path, handles)
+}
+
+// HEADNamed is a shortcut for router.HandleNamed(name, "HEAD", path, handle)
+func (r *Router) HEADNamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "HEAD", path, handles)
+}
+
+// POSTNamed is a shortcut for router.HandleNamed(name, "POST", path, handle)
+func (r *Router) POSTNamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "POST", path, handles)
+}
+
+// PUTNamed is a shortcut for router.HandleNamed(name, "PUT", path, handle)
+func (r *Router) PUTNamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "PUT", path, handles)
+}
+
+// PATCHNamed is a shortcut for router.HandleNamed(name, "PATCH", path, handle)
+func (r *Router) PATCHNamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "PATCH", path, handles)
+}
+
+// DELETENamed is a shortcut for router.HandleNamed(name, "DELETE", path, handle)
+func (r *Router) DELETENamed(name, path string, handles ...Handle) {
+	r.HandleNamed(name, "DELETE", path, handles)
+}
+
+// URL builds the path of the route registered under `name`, substituting its parameters with
+// the values in `params`. Named parameters are escaped; the value of a catch-all parameter can
+// contain slashes. For example:
+//
+//  r.GETNamed("user.show", "/users/:id", showUser)
+//
+//  path, err := r.URL("user.show", map[string]string{"id": "42"}) // "/users/42"
+//
+// An error is returned if no route has been registered under `name`, or if a parameter
+// doesn't have a value
+func (r *Router) URL(name string, params map[string]string) (string, error) {
+	path, ok := r.names[name]
+
+	if !ok {
+		return "", fmt.Errorf("no route named %q", name)
+	}
+
+	segments := strings.Split(path, "/")
+
+	for index, segment := range segments {
+		if len(segment) == 0 || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+
+		value := params[segment[1:]]
+
+		if value == "" {
+			return "", fmt.Errorf("missing value for parameter %q of route %q", segment[1:], name)
+		}
+
+		if segment[0] == ':' {
+			segments[index] = url.PathEscape(value)
+			continue
+		}
+
+		parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+
+		for i, part := range parts {
+			parts[i] = url.PathEscape(part)
+		}
+
+		segments[index] = strings.Join(parts, "/")
+	}
+
+	return strings.Join(segments, "/"), nil
+}
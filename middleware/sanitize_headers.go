@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+)
+
+// StandardRequestHeaders lists common request headers that most applications need. Pass it to
+// SanitizeHeaders, along with any header specific to your application, to keep them:
+//
+//  s.AddMiddleware(middleware.SanitizeHeaders(append(middleware.StandardRequestHeaders, "X-Api-Key")))
+var StandardRequestHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Authorization",
+	"Cache-Control",
+	"Connection",
+	"Content-Length",
+	"Content-Type",
+	"Cookie",
+	"If-Match",
+	"If-Modified-Since",
+	"If-None-Match",
+	"If-Unmodified-Since",
+	"Origin",
+	"Range",
+	"Referer",
+	"User-Agent",
+}
+
+// SanitizeHeaders returns a middleware that removes every request header that isn't listed in
+// `allow` before the request reaches the handlers, so that unexpected headers can't be used to
+// smuggle information past upstream proxies. Header names are matched case-insensitively.
+// Note that no header is kept unless it is listed; see StandardRequestHeaders.
+func SanitizeHeaders(allow []string) bowtie.Middleware {
+	allowed := map[string]bool{}
+
+	for _, name := range allow {
+		allowed[http.CanonicalHeaderKey(name)] = true
+	}
+
+	return func(c bowtie.Context, next func()) {
+		header := c.Request().Header
+
+		for name := range header {
+			if !allowed[http.CanonicalHeaderKey(name)] {
+				delete(header, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSanitizeHeaders(t *testing.T) {
+	s := bowtie.NewServer()
+
+	var header http.Header
+
+	s.AddMiddleware(SanitizeHeaders(append(StandardRequestHeaders, "x-api-key")))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		header = c.Request().Header
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Api-Key", "secret")
+	req.Header.Set("X-Forwarded-Host", "evil.example.com")
+	req.Header["x-lowercase"] = []string{"value"}
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	expect := func(name string, present bool) {
+		if _, ok := header[name]; ok != present {
+			t.Errorf("Expected header %s to be present: %v, got %v instead", name, present, ok)
+		}
+	}
+
+	expect("Accept", true)
+	expect("X-Api-Key", true)
+	expect("X-Forwarded-Host", false)
+	expect("x-lowercase", false)
+}
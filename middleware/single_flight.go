@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"sync"
+)
+
+// flight is a request whose response is shared with the identical requests that arrive
+// while it is running
+type flight struct {
+	done     chan struct{}
+	response *CachedResponse
+}
+
+// SingleFlight returns a middleware that coalesces concurrent GET requests that share the key
+// returned by `keyFunc` (or the request's URL, if `keyFunc` is nil): the first request runs the
+// rest of the chain, while the others wait for it to complete and receive a copy of its status,
+// headers, and body. This protects expensive endpoints from stampedes, for example when a cache
+// entry expires:
+//
+//  s.AddMiddleware(middleware.SingleFlight(nil))
+//  s.AddMiddlewareProvider(router)
+//
+// Because the whole response must be captured, SingleFlight turns on buffering for the requests
+// it handles. If the first request ends with errors (or panics), the waiting requests run the
+// chain themselves. Like Cache, SingleFlight only shares the headers set by the middleware that
+// runs after it, never shares Set-Cookie headers and, unless you
+// provide a `keyFunc`, lets requests that carry an Authorization or Cookie header run on their
+// own, since their responses may be specific to the user who made them.
+func SingleFlight(keyFunc func(c bowtie.Context) string) bowtie.Middleware {
+	skipCredentials := keyFunc == nil
+
+	if keyFunc == nil {
+		keyFunc = requestURL
+	}
+
+	mutex := sync.Mutex{}
+	flights := map[string]*flight{}
+
+	return func(c bowtie.Context, next func()) {
+		if c.Method() != "GET" || (skipCredentials && hasCredentials(c)) {
+			next()
+			return
+		}
+
+		res := c.Response()
+		key := keyFunc(c)
+
+		mutex.Lock()
+
+		if current, ok := flights[key]; ok {
+			mutex.Unlock()
+
+			<-current.done
+
+			if current.response != nil {
+				writeCachedResponse(res, current.response)
+				return
+			}
+
+			next()
+			return
+		}
+
+		current := &flight{done: make(chan struct{})}
+		flights[key] = current
+
+		mutex.Unlock()
+
+		defer func() {
+			mutex.Lock()
+			delete(flights, key)
+			mutex.Unlock()
+
+			close(current.done)
+		}()
+
+		before := res.Header().Clone()
+
+		res.SetBuffered(true)
+
+		next()
+
+		current.response = captureResponse(res, before)
+	}
+}
@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlight(t *testing.T) {
+	var calls int32
+
+	release := make(chan struct{})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(SingleFlight(nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		atomic.AddInt32(&calls, 1)
+
+		<-release
+
+		c.Response().Header().Set("X-Expensive", "yes")
+		c.Response().WriteString("report")
+	})
+
+	const count = 5
+
+	recorders := make([]*httptest.ResponseRecorder, count)
+	wg := sync.WaitGroup{}
+
+	for index := range recorders {
+		recorders[index] = httptest.NewRecorder()
+		wg.Add(1)
+
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest("GET", "/report", nil)
+
+			s.ServeHTTP(w, req)
+		}(recorders[index])
+	}
+
+	// Give every request a chance to join the flight before the handler completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("Expected the handler to run once, got %d calls instead", calls)
+	}
+
+	for _, w := range recorders {
+		if w.Code != http.StatusOK || w.Body.String() != "report" || w.Header().Get("X-Expensive") != "yes" {
+			t.Errorf("Unexpected shared response: %d %s %#v", w.Code, w.Body.String(), w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/report", nil)
+
+	s.ServeHTTP(w, req)
+
+	if calls != 2 || w.Body.String() != "report" {
+		t.Errorf("Expected a new request to run the handler again, got %d calls instead", calls)
+	}
+}
+
+func TestSingleFlightPrivateResponses(t *testing.T) {
+	var calls int32
+
+	release := make(chan struct{})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(SingleFlight(nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		atomic.AddInt32(&calls, 1)
+
+		<-release
+
+		http.SetCookie(c.Response(), &http.Cookie{Name: "session", Value: "leader"})
+		c.Response().WriteString("report")
+	})
+
+	requests := []string{"", "", "session=a", "session=b"}
+	recorders := make([]*httptest.ResponseRecorder, len(requests))
+	wg := sync.WaitGroup{}
+
+	for index, cookie := range requests {
+		recorders[index] = httptest.NewRecorder()
+		wg.Add(1)
+
+		go func(w *httptest.ResponseRecorder, cookie string) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest("GET", "/report", nil)
+
+			if cookie != "" {
+				req.Header.Set("Cookie", cookie)
+			}
+
+			s.ServeHTTP(w, req)
+		}(recorders[index], cookie)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 3 {
+		t.Errorf("Expected requests with cookies to run on their own, got %d calls instead", calls)
+	}
+
+	shared := 0
+
+	for _, w := range recorders {
+		if w.Body.String() != "report" {
+			t.Errorf("Unexpected response: %s", w.Body.String())
+		}
+
+		if w.Header().Get("Set-Cookie") == "" {
+			shared += 1
+		}
+	}
+
+	if shared != 1 {
+		t.Errorf("Expected exactly one response to be shared without its cookie, got %d instead", shared)
+	}
+}
+
+func TestSingleFlightUpstreamHeaders(t *testing.T) {
+	release := make(chan struct{})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(RequestID("X-Request-Id"))
+	s.AddMiddleware(SingleFlight(nil))
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		<-release
+
+		c.Response().WriteString("report")
+	})
+
+	ids := []string{"aaa", "bbb", "ccc"}
+	recorders := make([]*httptest.ResponseRecorder, len(ids))
+	wg := sync.WaitGroup{}
+
+	for index, id := range ids {
+		recorders[index] = httptest.NewRecorder()
+		wg.Add(1)
+
+		go func(w *httptest.ResponseRecorder, id string) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest("GET", "/report", nil)
+			req.Header.Set("X-Request-Id", id)
+
+			s.ServeHTTP(w, req)
+		}(recorders[index], id)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for index, w := range recorders {
+		if w.Body.String() != "report" || w.Header().Get("X-Request-Id") != ids[index] {
+			t.Errorf("Unexpected response for request %s: %s %#v", ids[index], w.Body.String(), w.Header())
+		}
+	}
+}
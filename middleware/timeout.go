@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"github.com/mtabini/go-bowtie"
+	"time"
+)
+
+// Timeout returns a middleware that applies a deadline of `d` to each request. The deadline is
+// reported by the context's RemainingTime() and CheckDeadline(), and cancels the request's
+// context.Context when it expires, so that handlers and the calls they make to other services
+// can give up on work whose result would arrive too late. A value of zero or less disables
+// the timeout.
+func Timeout(d time.Duration) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		if d <= 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.StdContext(), d)
+		defer cancel()
+
+		c.WithStdContext(ctx)
+
+		next()
+	}
+}
+
+// MethodTimeouts returns a middleware that works like Timeout, but picks the timeout from
+// `timeouts` based on the request's method, so that, for example, writes can be given a longer
+// budget than reads. Methods that don't appear in `timeouts` use `fallback`:
+//
+//  s.AddMiddleware(middleware.MethodTimeouts(map[string]time.Duration{
+//      "POST": 30 * time.Second,
+//      "PUT":  30 * time.Second,
+//  }, 5*time.Second))
+func MethodTimeouts(timeouts map[string]time.Duration, fallback time.Duration) bowtie.Middleware {
+	middlewares := map[string]bowtie.Middleware{}
+
+	for method, d := range timeouts {
+		middlewares[method] = Timeout(d)
+	}
+
+	fallbackMiddleware := Timeout(fallback)
+
+	return func(c bowtie.Context, next func()) {
+		if mw, ok := middlewares[c.Method()]; ok {
+			mw(c, next)
+			return
+		}
+
+		fallbackMiddleware(c, next)
+	}
+}
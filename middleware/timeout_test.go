@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMethodTimeouts(t *testing.T) {
+	bowtie.DefaultClock = bowtie.NewFakeClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() { bowtie.DefaultClock = bowtie.RealClock }()
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(MethodTimeouts(map[string]time.Duration{"POST": 30 * time.Second}, 5*time.Second))
+
+	var remaining time.Duration
+	var ctxErr error
+
+	s.AddMiddleware(func(c bowtie.Context, next func()) {
+		remaining, _ = c.RemainingTime()
+		ctxErr = c.StdContext().Err()
+	})
+
+	expect := func(method string, timeout time.Duration) {
+		remaining = 0
+
+		req, _ := http.NewRequest(method, "/", nil)
+
+		s.ServeHTTP(httptest.NewRecorder(), req)
+
+		if remaining <= timeout-time.Second || remaining > timeout {
+			t.Errorf("Expected a timeout of %s for %s, got %s instead", timeout, method, remaining)
+		}
+
+		if ctxErr != nil {
+			t.Errorf("Unexpected context error for %s: %s", method, ctxErr)
+		}
+	}
+
+	expect("GET", 5*time.Second)
+	expect("POST", 30*time.Second)
+}
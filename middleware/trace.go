@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+)
+
+// Interface Tracer starts tracing spans. It is deliberately small, so that it can be bridged
+// to OpenTelemetry or any other tracing library without making Bowtie depend on it.
+type Tracer interface {
+	// StartSpan starts a new span called `name`
+	StartSpan(name string) Span
+}
+
+// Interface Span is a span started by a Tracer
+type Span interface {
+	// SetName changes the name of the span
+	SetName(name string)
+	// SetAttr sets the attribute `key` of the span to `value`
+	SetAttr(key string, value interface{})
+	// End marks the span as complete
+	End()
+}
+
+// Trace returns a middleware that starts a span for each request. Because the route isn't known
+// until a router has matched the request, the span is started under the request's method and,
+// once the request has been handled, renamed after the method and the matched route (for
+// example, `GET /users/:id`) as recommended by the OpenTelemetry semantic conventions. The span
+// carries the `http.method`, `http.route` (for matched requests only) and `http.status_code`
+// attributes. Add it to the server ahead of the router, so that unmatched requests, and the
+// time spent routing, are traced as well:
+//
+//  s.AddMiddleware(middleware.Trace(tracer))
+//  s.AddMiddlewareProvider(router)
+func Trace(tracer Tracer) bowtie.Middleware {
+	return func(c bowtie.Context, next func()) {
+		span := tracer.StartSpan(c.Method())
+
+		span.SetAttr("http.method", c.Method())
+
+		c.Defer(func() {
+			if route := GetRoute(c); route != "" {
+				span.SetName(c.Method() + " " + route)
+				span.SetAttr("http.route", route)
+			}
+
+			span.SetAttr("http.status_code", c.Response().Status())
+			span.End()
+		})
+
+		next()
+	}
+}
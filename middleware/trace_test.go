@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"github.com/mtabini/go-bowtie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	ended bool
+}
+
+func (s *testSpan) SetName(name string) {
+	s.name = name
+}
+
+func (s *testSpan) SetAttr(key string, value interface{}) {
+	if s.ended {
+		panic("attribute set on a span that has ended")
+	}
+
+	s.attrs[key] = value
+}
+
+func (s *testSpan) End() {
+	s.ended = true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(name string) Span {
+	span := &testSpan{name: name, attrs: map[string]interface{}{}}
+
+	t.spans = append(t.spans, span)
+
+	return span
+}
+
+func TestTrace(t *testing.T) {
+	tracer := &testTracer{}
+
+	r := NewRouter()
+
+	r.POST("/users/:id", func(c bowtie.Context) {
+		if len(tracer.spans) != 1 || tracer.spans[0].ended {
+			t.Error("Expected the span to be open while the handler runs")
+		}
+
+		c.Response().WriteHeader(http.StatusCreated)
+	})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Trace(tracer))
+	s.AddMiddlewareProvider(r)
+
+	req, _ := http.NewRequest("POST", "/users/42", nil)
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected one span, got %d instead", len(tracer.spans))
+	}
+
+	span := tracer.spans[0]
+
+	if span.name != "POST /users/:id" {
+		t.Errorf("Unexpected span name: %s", span.name)
+	}
+
+	if !span.ended {
+		t.Error("Expected the span to have ended")
+	}
+
+	expected := map[string]interface{}{
+		"http.method":      "POST",
+		"http.route":       "/users/:id",
+		"http.status_code": http.StatusCreated,
+	}
+
+	for key, value := range expected {
+		if span.attrs[key] != value {
+			t.Errorf("Expected attribute %s to be %v, got %v instead", key, value, span.attrs[key])
+		}
+	}
+}
+
+func TestTraceUnmatched(t *testing.T) {
+	tracer := &testTracer{}
+
+	r := NewRouter()
+
+	r.GET("/users/:id", func(c bowtie.Context) {})
+
+	s := bowtie.NewServer()
+
+	s.AddMiddleware(Trace(tracer))
+	s.AddMiddleware(ErrorReporter)
+	s.AddMiddlewareProvider(r)
+
+	req, _ := http.NewRequest("GET", "/missing", nil)
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected one span, got %d instead", len(tracer.spans))
+	}
+
+	span := tracer.spans[0]
+
+	if span.name != "GET" || !span.ended {
+		t.Errorf("Unexpected span for an unmatched request: %s (ended: %v)", span.name, span.ended)
+	}
+
+	if _, ok := span.attrs["http.route"]; ok {
+		t.Errorf("Expected no route attribute, got %v instead", span.attrs["http.route"])
+	}
+
+	if span.attrs["http.status_code"] != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %v instead", http.StatusNotFound, span.attrs["http.status_code"])
+	}
+}
@@ -9,8 +9,25 @@ import (
 	"github.com/mtabini/go-bowtie/middleware"
 )
 
+// Names of the middlewares installed by New. You can pass them to RemoveMiddleware to
+// replace the defaults with your own.
+const (
+	LoggerMiddleware   = "logger"
+	RecoveryMiddleware = "recovery"
+	ErrorMiddleware    = "errors"
+	CORSMiddleware     = "cors"
+	RouterMiddleware   = "router"
+)
+
 // Struct QuickServer encapsulates a Bowtie server and router. It can be passed
 // directly to net/http.ListenAndServe, and exposes all the router's methods.
+//
+// The default middlewares are installed by name, so they can be listed with MiddlewareNames()
+// and removed with RemoveMiddleware(). For example, to use your own logger:
+//
+//  s := quick.New()
+//
+//  s.RemoveMiddleware(quick.LoggerMiddleware)
 type QuickServer struct {
 	*bowtie.Server
 	*middleware.Router
@@ -22,17 +39,17 @@ func New() *QuickServer {
 
 	s := bowtie.NewServer()
 
-	s.AddMiddleware(middleware.NewLogger(middleware.MakePlaintextLogger()))
-	s.AddMiddleware(middleware.Recovery)
-	s.AddMiddleware(middleware.ErrorReporter)
+	s.AddNamedMiddleware(LoggerMiddleware, middleware.NewLogger(middleware.MakePlaintextLogger()))
+	s.AddNamedMiddleware(RecoveryMiddleware, middleware.Recovery)
+	s.AddNamedMiddleware(ErrorMiddleware, middleware.ErrorReporter)
 
 	cors := middleware.NewCORSHandler(r)
 
 	cors.SetDefaults()
 
-	s.AddMiddlewareProvider(cors)
+	s.AddNamedMiddlewareProvider(CORSMiddleware, cors)
 
-	s.AddMiddlewareProvider(r)
+	s.AddNamedMiddlewareProvider(RouterMiddleware, r)
 
 	return &QuickServer{
 		s,
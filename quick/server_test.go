@@ -0,0 +1,51 @@
+package quick
+
+import (
+	"bytes"
+	"github.com/mtabini/go-bowtie"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestQuickServerRemoveMiddleware(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stderr)
+
+	s := New()
+
+	s.GET("/test", func(c bowtie.Context) {
+		c.Response().WriteString("ok")
+	})
+
+	if !s.RemoveMiddleware(LoggerMiddleware) {
+		t.Fatal("Unable to remove the default logger")
+	}
+
+	if s.RemoveMiddleware(LoggerMiddleware) {
+		t.Error("Default logger unexpectedly removed twice")
+	}
+
+	for _, name := range s.MiddlewareNames() {
+		if name == LoggerMiddleware {
+			t.Error("Default logger is still listed after being removed")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Body.String() != "ok" {
+		t.Errorf("Unexpected response: %s", w.Body.String())
+	}
+
+	if out.Len() > 0 {
+		t.Errorf("Default logger unexpectedly ran: %s", out.String())
+	}
+}
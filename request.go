@@ -1,20 +1,355 @@
 package bowtie
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
+// ErrInvalidCookieSignature is returned by Request.SignedCookie when a cookie's signature
+// doesn't match its value, which means that the cookie has been tampered with
+var ErrInvalidCookieSignature = errors.New("bowtie: invalid cookie signature")
+
+// DefaultMaxMultipartMemory is the default value of Request.MaxMultipartMemory
+const DefaultMaxMultipartMemory = 32 << 20
+
 // Struct Request adds a few convenience functions to `http.Request`.
 type Request struct {
 	*http.Request
+	// MaxMultipartMemory is the maximum number of bytes of a multipart body that FormFile keeps
+	// in memory; the remainder of any file is stored on disk in temporary files
+	MaxMultipartMemory int64
+	body               *countingReader
+	trustedProxies     []*net.IPNet
+}
+
+// countingReader wraps a request's body and counts the bytes read from it
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+
+	atomic.AddInt64(&c.count, int64(n))
+
+	return n, err
 }
 
 // NewRequest creates a new request instance. This is called transparently for you
 // at the time the server receives a request
 func NewRequest(r *http.Request) *Request {
-	return &Request{r}
+	result := &Request{
+		Request:            r,
+		MaxMultipartMemory: DefaultMaxMultipartMemory,
+	}
+
+	if r.Body != nil {
+		result.body = &countingReader{ReadCloser: r.Body}
+		r.Body = result.body
+	}
+
+	return result
+}
+
+// BytesRead returns the number of bytes that have been read from the request's body so far
+func (r *Request) BytesRead() int64 {
+	if r.body == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&r.body.count)
+}
+
+// FormFile works like http.Request.FormFile, but parses multipart bodies using
+// MaxMultipartMemory as the limit on the amount of data held in memory
+func (r *Request) FormFile(key string) (multipart.File, *multipart.FileHeader, error) {
+	if r.MultipartForm == nil {
+		if err := r.ParseMultipartForm(r.MaxMultipartMemory); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return r.Request.FormFile(key)
+}
+
+// SignedCookie returns the value of the cookie called `name`, after verifying that it was signed
+// with `key` by ResponseWriter.SetSignedCookie. It returns http.ErrNoCookie if the cookie is
+// missing, and ErrInvalidCookieSignature if its signature is invalid
+func (r *Request) SignedCookie(name string, key []byte) (string, error) {
+	cookie, err := r.Cookie(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	separator := strings.LastIndex(cookie.Value, ".")
+
+	if separator < 0 {
+		return "", ErrInvalidCookieSignature
+	}
+
+	value := cookie.Value[:separator]
+	signature, err := base64.RawURLEncoding.DecodeString(cookie.Value[separator+1:])
+
+	if err != nil || !hmac.Equal(signature, cookieSignature(name, value, key)) {
+		return "", ErrInvalidCookieSignature
+	}
+
+	return value, nil
+}
+
+// cookieSignature computes the HMAC-SHA256 of a cookie's name and value. The name is included
+// so that a signed value can't be moved to a different cookie
+func cookieSignature(name, value string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	mac.Write([]byte(name + "=" + value))
+
+	return mac.Sum(nil)
+}
+
+// parseTrustedProxies converts a list of CIDRs (or plain IP addresses) into networks. An entry
+// that cannot be parsed causes an error, since ignoring it would silently stop trusting a proxy
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	result := []*net.IPNet{}
+
+	for _, proxy := range proxies {
+		cidr := proxy
+
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			return nil, fmt.Errorf("bowtie: invalid trusted proxy %q", proxy)
+		}
+
+		result = append(result, network)
+	}
+
+	return result, nil
+}
+
+// isTrustedProxy returns true if `ip` belongs to one of the request's trusted proxies
+func (r *Request) isTrustedProxy(ip net.IP) bool {
+	for _, network := range r.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteHost returns the host portion of the request's RemoteAddr
+func (r *Request) remoteHost() string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ClientIP returns the IP address of the client that made the request. Forwarding headers
+// (X-Forwarded-For and X-Real-Ip) are only honored if the request comes from one of the
+// trusted proxies configured on the server, in which case the address returned is the
+// closest one that doesn't belong to a trusted proxy.
+func (r *Request) ClientIP() string {
+	remote := r.remoteHost()
+
+	if ip := net.ParseIP(remote); ip == nil || !r.isTrustedProxy(ip) {
+		return remote
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+
+		for index := len(hops) - 1; index >= 0; index-- {
+			hop := strings.TrimSpace(hops[index])
+			ip := net.ParseIP(hop)
+
+			if ip == nil {
+				break
+			}
+
+			if !r.isTrustedProxy(ip) || index == 0 {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return remote
+}
+
+// Scheme returns the scheme (http or https) that the client used to make the request. The
+// X-Forwarded-Proto header is only honored if the request comes from one of the trusted
+// proxies configured on the server; otherwise, the scheme depends on whether the request was
+// received over TLS.
+func (r *Request) Scheme() string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if ip := net.ParseIP(r.remoteHost()); ip != nil && r.isTrustedProxy(ip) {
+		proto := strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]
+		proto = strings.ToLower(strings.TrimSpace(proto))
+
+		if proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+
+	return "http"
+}
+
+// ExpectsBody returns true if the request's method is one that normally carries a body, that
+// is, POST, PUT, or PATCH
+func (r *Request) ExpectsBody() bool {
+	return r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH"
+}
+
+// ProtoAtLeast returns true if the request was made using HTTP version `major`.`minor` or
+// later. HTTP/1.0 clients, for example, don't understand chunked transfer encoding
+func (r *Request) ProtoAtLeast(major, minor int) bool {
+	return r.Request.ProtoAtLeast(major, minor)
+}
+
+// acceptRange is a media range taken from an Accept header, along with its quality
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity returns how specifically the range matches `offer`: 2 for an exact match, 1 for
+// a `type/*` match, 0 for `*/*`, and -1 if the range doesn't match at all
+func (a acceptRange) specificity(offer string) int {
+	switch {
+	case strings.EqualFold(a.mediaType, offer):
+		return 2
+	case strings.HasSuffix(a.mediaType, "/*") && a.mediaType != "*/*":
+		if strings.HasPrefix(strings.ToLower(offer), strings.ToLower(strings.TrimSuffix(a.mediaType, "*"))) {
+			return 1
+		}
+	case a.mediaType == "*/*":
+		return 0
+	}
+
+	return -1
+}
+
+// parseAccept parses the media ranges in an Accept header
+func parseAccept(accept string) []acceptRange {
+	result := []acceptRange{}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		result = append(result, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	return result
+}
+
+// Negotiate returns the media type among `offers` that best matches the request's Accept
+// header. If none of the offers is acceptable, or the request has no Accept header, the first
+// offer is returned; use NegotiateStrict to reject such requests instead.
+func (r *Request) Negotiate(offers ...string) string {
+	if best, err := r.NegotiateStrict(offers...); err == nil {
+		return best
+	}
+
+	if len(offers) > 0 {
+		return offers[0]
+	}
+
+	return ""
+}
+
+// NegotiateStrict works like Negotiate, but returns a 406 Not Acceptable Error that lists the
+// available representations if none of `offers` is acceptable to the client. Offers are matched
+// against the most specific media range that applies to them, and ties between offers are
+// resolved in favour of the one that comes first.
+func (r *Request) NegotiateStrict(offers ...string) (string, error) {
+	accept := r.Header.Get("Accept")
+
+	if accept == "" && len(offers) > 0 {
+		return offers[0], nil
+	}
+
+	ranges := parseAccept(accept)
+
+	best := ""
+	bestQ := 0.0
+
+	for _, offer := range offers {
+		specificity := -1
+		q := 0.0
+
+		for _, a := range ranges {
+			if s := a.specificity(offer); s > specificity {
+				specificity = s
+				q = a.q
+			}
+		}
+
+		if specificity >= 0 && q > bestQ {
+			best = offer
+			bestQ = q
+		}
+	}
+
+	if best == "" {
+		return "", NewNotAcceptableError(offers)
+	}
+
+	return best, nil
+}
+
+// RequestLine returns the request line as it would appear on the wire, for example
+// `GET /test?x=1 HTTP/1.1`
+func (r *Request) RequestLine() string {
+	return r.Method + " " + r.URL.RequestURI() + " " + r.Proto
 }
 
 // StringBody returns the request's body as a string
@@ -53,3 +388,147 @@ func (r *Request) ReadJSONBody(v interface{}) error {
 
 	return nil
 }
+
+// JSONLines reads the request's body as newline-delimited JSON, calling `fn` with each line as
+// it is read, so that large bodies can be processed without loading them into memory. Blank
+// lines are skipped. Iteration stops at the first line that isn't valid JSON, or as soon as
+// `fn` returns an error, which is then returned by JSONLines.
+func (r *Request) JSONLines(fn func(line json.RawMessage) error) error {
+	if r.Body == nil {
+		return nil
+	}
+
+	reader := bufio.NewReader(r.Body)
+
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			if !json.Valid(trimmed) {
+				return fmt.Errorf("invalid JSON on line %d", number)
+			}
+
+			if fnErr := fn(json.RawMessage(trimmed)); fnErr != nil {
+				return fnErr
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// BindQuery copies the request's query parameters into the fields of the struct pointed to by
+// `v`. Fields are mapped to parameters through the `query` tag; for example:
+//
+//  type ListOptions struct {
+//      Page     int    `query:"page"`
+//      Sort     string `query:"sort"`
+//      Reversed bool   `query:"reversed"`
+//  }
+//
+// String, boolean, integer and floating-point fields are supported, as are slices thereof,
+// which receive every value of a repeated parameter. Parameters that are missing from the
+// query leave the corresponding field untouched. If a value cannot be converted to the type
+// of its field, BindQuery returns an Error with a 400 status code.
+func (r *Request) BindQuery(v interface{}) error {
+	target := reflect.ValueOf(v)
+
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return NewError(500, "BindQuery requires a pointer to a struct, got %T", v)
+	}
+
+	target = target.Elem()
+	targetType := target.Type()
+	query := r.URL.Query()
+
+	for index := 0; index < targetType.NumField(); index++ {
+		field := targetType.Field(index)
+		name := field.Tag.Get("query")
+
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		values, ok := query[name]
+
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		value := target.Field(index)
+
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+
+			for i, s := range values {
+				if err := setQueryValue(slice.Index(i), s); err != nil {
+					return NewError(400, "Invalid value %q for query parameter %s", s, name)
+				}
+			}
+
+			value.Set(slice)
+			continue
+		}
+
+		if err := setQueryValue(value, values[0]); err != nil {
+			return NewError(400, "Invalid value %q for query parameter %s", values[0], name)
+		}
+	}
+
+	return nil
+}
+
+// setQueryValue converts `s` to the type of `value` and stores it there
+func setQueryValue(value reflect.Value, s string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+
+		if err != nil {
+			return err
+		}
+
+		value.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		value.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package bowtie
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRequestLine(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://example.com/test/path?x=1&y=2", nil)
+
+	if line := NewRequest(r).RequestLine(); line != "GET /test/path?x=1&y=2 HTTP/1.1" {
+		t.Errorf("Unexpected request line: %s", line)
+	}
+}
+
+type testQuery struct {
+	Page     int      `query:"page"`
+	Sort     string   `query:"sort"`
+	Reversed bool     `query:"reversed"`
+	Tags     []string `query:"tag"`
+	Ignored  string
+}
+
+func TestRequestBindQuery(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/items?page=3&sort=name&reversed=true&tag=a&tag=b&Ignored=x", nil)
+
+	q := testQuery{}
+
+	if err := NewRequest(r).BindQuery(&q); err != nil {
+		t.Fatalf("Unable to bind query: %s", err)
+	}
+
+	if q.Page != 3 || q.Sort != "name" || !q.Reversed || len(q.Tags) != 2 || q.Tags[1] != "b" || q.Ignored != "" {
+		t.Errorf("Unexpected bound query: %#v", q)
+	}
+
+	r, _ = http.NewRequest("GET", "/items?page=three", nil)
+
+	err := NewRequest(r).BindQuery(&q)
+
+	if e, ok := err.(Error); !ok || e.StatusCode() != 400 {
+		t.Errorf("Expected a 400 error for an invalid value, got %v instead", err)
+	}
+}
+
+func TestRequestMaxMultipartMemory(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, _ := mw.CreateFormFile("upload", "data.bin")
+	content := bytes.Repeat([]byte("x"), 64*1024)
+
+	part.Write(content)
+	mw.Close()
+
+	r, _ := http.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	req := NewRequest(r)
+
+	if req.MaxMultipartMemory != DefaultMaxMultipartMemory {
+		t.Errorf("Expected a default limit of %d, got %d instead", DefaultMaxMultipartMemory, req.MaxMultipartMemory)
+	}
+
+	req.MaxMultipartMemory = 1024
+
+	f, header, err := req.FormFile("upload")
+
+	if err != nil {
+		t.Fatalf("Unable to read uploaded file: %s", err)
+	}
+
+	defer req.MultipartForm.RemoveAll()
+	defer f.Close()
+
+	if _, ok := f.(*os.File); !ok {
+		t.Error("Expected the upload to be stored on disk")
+	}
+
+	if header.Size != int64(len(content)) {
+		t.Errorf("Expected %d bytes, got %d instead", len(content), header.Size)
+	}
+
+	if data, _ := ioutil.ReadAll(f); !bytes.Equal(data, content) {
+		t.Error("Unexpected file contents")
+	}
+}
+
+func TestRequestSignedCookie(t *testing.T) {
+	key := []byte("secret")
+
+	w := httptest.NewRecorder()
+
+	NewResponseWriter(w).SetSignedCookie(&http.Cookie{Name: "session", Value: "abc123"}, key)
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %d instead", len(cookies))
+	}
+
+	expect := func(cookie *http.Cookie, value string, expectedErr error) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.AddCookie(cookie)
+
+		v, err := NewRequest(r).SignedCookie(cookie.Name, key)
+
+		if v != value || err != expectedErr {
+			t.Errorf("Expected %q (%v) for %s, got %q (%v) instead", value, expectedErr, cookie.Value, v, err)
+		}
+	}
+
+	expect(cookies[0], "abc123", nil)
+	expect(&http.Cookie{Name: "session", Value: strings.Replace(cookies[0].Value, "abc123", "abc124", 1)}, "", ErrInvalidCookieSignature)
+	expect(&http.Cookie{Name: "other", Value: cookies[0].Value}, "", ErrInvalidCookieSignature)
+	expect(&http.Cookie{Name: "session", Value: "abc123"}, "", ErrInvalidCookieSignature)
+}
+
+func TestRequestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml"}
+
+	expect := func(accept, expected string, status int) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", accept)
+
+		req := NewRequest(r)
+		best, err := req.NegotiateStrict(offers...)
+
+		if best != expected {
+			t.Errorf("Expected %q for %q, got %q instead", expected, accept, best)
+		}
+
+		if status == 0 && err != nil {
+			t.Errorf("Unexpected error for %q: %s", accept, err)
+		}
+
+		if status != 0 {
+			if e, ok := err.(Error); !ok || e.StatusCode() != status {
+				t.Errorf("Expected a %d error for %q, got %v instead", status, accept, err)
+			}
+
+			if fallback := req.Negotiate(offers...); fallback != offers[0] {
+				t.Errorf("Expected Negotiate to fall back to %s, got %s instead", offers[0], fallback)
+			}
+		}
+	}
+
+	expect("", "application/json", 0)
+	expect("application/xml", "application/xml", 0)
+	expect("application/json;q=0.5, application/xml", "application/xml", 0)
+	expect("application/*, application/json;q=0", "application/xml", 0)
+	expect("*/*", "application/json", 0)
+	expect("image/png", "", http.StatusNotAcceptable)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html")
+
+	if _, err := NewRequest(r).NegotiateStrict(offers...); err.Error() != "Not acceptable; available representations: application/json, application/xml" {
+		t.Errorf("Unexpected error message: %s", err)
+	}
+}
+
+func TestRequestJSONLines(t *testing.T) {
+	body := "{\"id\":1}\n\n{\"id\":2}\r\n{\"id\":3}"
+
+	r, _ := http.NewRequest("POST", "/ingest", strings.NewReader(body))
+
+	ids := []int{}
+
+	err := NewRequest(r).JSONLines(func(line json.RawMessage) error {
+		item := struct{ ID int }{}
+
+		if err := json.Unmarshal(line, &item); err != nil {
+			return err
+		}
+
+		ids = append(ids, item.ID)
+
+		return nil
+	})
+
+	if err != nil || len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("Unexpected result of processing lines: %v, %v", ids, err)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+
+	r, _ = http.NewRequest("POST", "/ingest", strings.NewReader(body))
+
+	err = NewRequest(r).JSONLines(func(line json.RawMessage) error {
+		count += 1
+
+		return stop
+	})
+
+	if err != stop || count != 1 {
+		t.Errorf("Expected iteration to stop after the first line, got %d lines and %v instead", count, err)
+	}
+
+	r, _ = http.NewRequest("POST", "/ingest", strings.NewReader("{\"id\":1}\n{oops\n{\"id\":3}\n"))
+	count = 0
+
+	err = NewRequest(r).JSONLines(func(line json.RawMessage) error {
+		count += 1
+
+		return nil
+	})
+
+	if err == nil || count != 1 {
+		t.Errorf("Expected an error on the second line, got %d lines and %v instead", count, err)
+	}
+}
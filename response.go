@@ -1,38 +1,199 @@
 package bowtie
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// ErrWriteAfterError is returned by the response writer when a handler attempts to write
+// to the body after an error has been added to it.
+var ErrWriteAfterError = errors.New("bowtie: body write ignored after an error was added")
+
+// DefaultMaxErrors is the default maximum number of errors that a response writer stores
+const DefaultMaxErrors = 100
+
 type ResponseWriterFactory func(w http.ResponseWriter) ResponseWriter
 
+// ResponseTransformer is a function that can wrap or rewrite the data passed to WriteJSON
+// before it is serialized. It is not applied to the output of error reporters.
+type ResponseTransformer func(data interface{}) interface{}
+
+// ErrorTransformer is a function that can redact or rewrite an error before an error reporter
+// outputs it, for example to map internal messages to public ones. The errors returned by
+// Errors() are not affected, so that loggers still see the originals.
+type ErrorTransformer func(err Error) Error
+
 // Interface ResponseWriter extends the functionality provided by `http.ResponseWriter`, mainly
 // by adding a few convenience methods for writing strings and JSON data and dealing with errors.
 //
 // You can provide your own extended ResponseWriter by creating a custom ResponseWriterFactory
 // function and setting it to the ResponseWriterFactory property of your server.
+//
+// Once an error has been added to the writer, the body belongs to whichever middleware reports
+// errors (for example, middleware.ErrorReporter); any further writes to the body are discarded
+// and return ErrWriteAfterError, so that a response can never mix error and regular output.
+// Error reporters must use `WriteErrorBody` to output the errors.
 type ResponseWriter interface {
 	http.ResponseWriter
 
-	// Add error safely adds a new error to the context, converting it to bowtie.Error if appropriate
+	// Add error safely adds a new error to the context, converting it to bowtie.Error if appropriate.
+	// The response's status is set to the error's, unless a status has already been sent
 	AddError(err error)
 
 	// Errors returns an array that contains any error assigned to the response writer
 	Errors() []Error
 
+	// SetMaxErrors sets the maximum number of errors that the writer stores. Errors added beyond
+	// that are counted, but not stored; instead, Errors() reports them through a final entry that
+	// states how many have been suppressed. A value of zero or less disables the limit
+	SetMaxErrors(n int)
+
+	// ErrorsWithStatus returns the errors assigned to the response writer whose status code is `code`
+	ErrorsWithStatus(code int) []Error
+
+	// ErrorsJSON marshals the response's errors to a JSON array of their public representations,
+	// for example for a debug console. If `includePrivate` is true, each error's private
+	// representation, which can contain sensitive data, is included under `private`
+	ErrorsJSON(includePrivate bool) ([]byte, error)
+
+	// HasError returns true if at least one error has been assigned to the response writer
+	HasError() bool
+
 	// Status returns the HTTP status code of the writer. You can set this by using `WriteHeader()`
 	Status() int
 
+	// SetStatus records `code` as the status of the response without writing it to the output
+	// stream, so that a later middleware can still change it. The status is written out when the
+	// body is first written to or, at the latest, when the request ends. SetStatus has no effect
+	// once a status has been written
+	SetStatus(code int)
+
 	// Written returns true if any data (including a status code) has been written to the writer's
 	// output stream
 	Written() bool
 
+	// StatusCommitted returns true if the status and headers have been sent to the client, after
+	// which they can no longer be changed. This is the case once a status has been written or any
+	// part of the body has been sent, but never while the response is buffered
+	StatusCommitted() bool
+
+	// BytesWritten returns the number of bytes of the body that have been sent to the client
+	// so far. Bytes held in the buffer of a buffered response are only counted once they are sent
+	BytesWritten() int64
+
+	// NoContent writes a 204 No Content status. Because 204 responses can't have a body, any
+	// subsequent write is discarded and returns http.ErrBodyNotAllowed
+	NoContent()
+
 	// WriteOrError checks if `err` is not nil, in which case it adds it to the context's error
 	// list and returns. If `err` is nil, `p` is written to the output stream instead. This is a
 	// convenient way of dealing with functions that return (data, error) tuples inside a middleware
 	WriteOrError(p []byte, err error) (int, error)
 
+	// SetTrailer sets an HTTP trailer that is sent after the body of the response. Trailers
+	// should be announced to the client before the body is written; SetTrailer does so
+	// automatically if the response's headers haven't been sent yet, so call it early (with an
+	// empty value, if necessary) and again once the final value is known
+	SetTrailer(key, value string)
+
+	// SetSignedCookie adds a Set-Cookie header for `cookie`, whose value is signed with `key`
+	// using HMAC-SHA256 so that it can be verified with Request.SignedCookie
+	SetSignedCookie(cookie *http.Cookie, key []byte)
+
+	// WritePage writes `items` in JSON format to the output stream and sets a Link header
+	// (RFC 5988) that points to other pages of the collection. `links` maps relation types
+	// (like `next`, `prev`, `first`, and `last`) to URLs
+	WritePage(items interface{}, links map[string]string) (int, error)
+
+	// WriteCollection writes `items` in JSON format to the output stream and sets the
+	// X-Total-Count header to `total`, the size of the whole collection, so that clients can
+	// paginate through it. The header is also added to Access-Control-Expose-Headers, so that
+	// browsers let cross-origin clients read it
+	WriteCollection(items interface{}, total int) (int, error)
+
+	// WriteProblem writes an RFC 7807 problem details object built from `status`, `title`, and
+	// `detail` (see NewProblemError) with a Content-Type of `application/problem+json`. Unlike
+	// AddError, it doesn't record an error, so the body is written even without an error reporter
+	WriteProblem(status int, title, detail string) (int, error)
+
+	// WriteAttachment copies the contents of `reader` to the output stream as a download named
+	// `filename`, setting the Content-Disposition and Content-Type headers accordingly
+	WriteAttachment(filename, contentType string, reader io.Reader) (int64, error)
+
+	// WriteNDJSON writes `item` in JSON format, followed by a newline, to the output stream and
+	// flushes it to the client, so that a collection can be streamed one item at a time. The
+	// Content-Type header is set to `application/x-ndjson` if no other type has been set
+	WriteNDJSON(item interface{}) (int, error)
+
+	// Flush sends any data written so far to the client, if the underlying writer supports it.
+	// It has no effect while the response is buffered
+	Flush()
+
+	// SetChunked tells the writer whether the client understands chunked transfer encoding. The
+	// server turns it off for HTTP/1.0 requests, in which case streaming writers like WriteNDJSON
+	// and SSE mark the response with `Connection: close`, since the end of a streamed body can
+	// only be signalled by closing the connection
+	SetChunked(enabled bool)
+
+	// Chunked returns false if the client doesn't understand chunked transfer encoding
+	Chunked() bool
+
+	// SSE returns a writer that streams Server-Sent Events to the client. Because each event must
+	// be flushed as soon as it is sent, SSE turns buffering off and returns ErrFlushNotSupported
+	// if the underlying writer can't be flushed
+	SSE() (*SSEWriter, error)
+
+	// SetResponseTransformer sets a function that transforms any data written as JSON
+	SetResponseTransformer(t ResponseTransformer)
+
+	// SetErrorTransformer sets a function that error reporters apply to each error before output
+	SetErrorTransformer(t ErrorTransformer)
+
+	// TransformError applies the error transformer to `err`. If no transformer has been set,
+	// `err` is returned unchanged
+	TransformError(err Error) Error
+
+	// SetBuffered enables or disables buffering. While buffering is enabled, the status and
+	// body of the response are held in memory and only sent when the request ends, which allows
+	// the body to be discarded with DiscardBody. Buffering can only be enabled before anything
+	// has been written; disabling it sends whatever has been buffered so far
+	SetBuffered(enabled bool)
+
+	// DiscardBody discards any body that has been buffered so far. It has no effect if the
+	// response is not buffered
+	DiscardBody()
+
+	// BufferedBody returns the body that has been buffered so far, or nil if the response is
+	// not buffered. The returned slice is only valid until the next write
+	BufferedBody() []byte
+
+	// SetAutoCaptureStacks enables or disables the automatic capture of stack traces. When
+	// enabled, AddError captures a stack trace for any 5xx error that doesn't already have one
+	SetAutoCaptureStacks(enabled bool)
+
+	// SetSniffJSON enables or disables JSON sniffing. When enabled, a body that looks like JSON
+	// (that is, starts with `{` or `[`) is sent with a Content-Type of `application/json` if no
+	// other content type has been set
+	SetSniffJSON(enabled bool)
+
+	// SetDefaultContentType sets the content type that is sent when a body is written without
+	// a Content-Type header, instead of letting net/http guess one. JSON sniffing, if enabled,
+	// takes precedence. An empty string restores the standard behavior
+	SetDefaultContentType(contentType string)
+
+	// WriteErrorBody writes `p` to the output stream even if errors have been added to the writer.
+	// It is meant to be used by middlewares that report errors to the client
+	WriteErrorBody(p []byte) (int, error)
+
 	// WriteString is a convenience method that outputs a string
 	WriteString(s string) (int, error)
 
@@ -44,6 +205,10 @@ type ResponseWriter interface {
 	// is also automatically set to `application/json`
 	WriteJSON(data interface{}) (int, error)
 
+	// WriteJSONWithType works like `WriteJSON`, but sets the output Content-Type header to
+	// `contentType` instead. This is useful for emitting vendor-specific media types
+	WriteJSONWithType(contentType string, data interface{}) (int, error)
+
 	// WriteJSONOrError checks if `err` is not nil, in which case it adds it to the context's error
 	// list and returns. If `err` is nil, `data` is serialized to JSON and written to the output
 	// stream instead; the Content-Type of the response is also set to `application/json` automatically.
@@ -54,9 +219,23 @@ type ResponseWriter interface {
 
 type ResponseWriterInstance struct {
 	http.ResponseWriter
-	written bool
-	errors  []Error
-	status  int
+	written       bool
+	errors        []Error
+	status        int
+	transformer   ResponseTransformer
+	errTransform  ErrorTransformer
+	sniffJSON     bool
+	defaultType   string
+	statusPending bool
+	buffer        *bytes.Buffer
+	noContent     bool
+	maxErrors     int
+	suppressed    int
+	suppressedMax int
+	autoCapture   bool
+	noChunked     bool
+	bytesWritten  int64
+	bodyStarted   bool
 }
 
 var _ ResponseWriter = &ResponseWriterInstance{}
@@ -66,23 +245,94 @@ func NewResponseWriter(w http.ResponseWriter) ResponseWriter {
 		ResponseWriter: w,
 		errors:         []Error{},
 		status:         200,
+		maxErrors:      DefaultMaxErrors,
 	}
 }
 
 // Errors returns an array that contains any error assigned to the response writer
 func (r *ResponseWriterInstance) Errors() []Error {
-	return r.errors
+	if r.suppressed == 0 {
+		return r.errors
+	}
+
+	result := make([]Error, len(r.errors), len(r.errors)+1)
+
+	copy(result, r.errors)
+
+	return append(result, NewError(r.suppressedMax, "%d more errors suppressed", r.suppressed))
+}
+
+// SetMaxErrors sets the maximum number of errors that the writer stores. Errors added beyond
+// that are counted, but not stored; instead, Errors() reports them through a final entry that
+// states how many have been suppressed. A value of zero or less disables the limit
+func (r *ResponseWriterInstance) SetMaxErrors(n int) {
+	r.maxErrors = n
+}
+
+// ErrorsWithStatus returns the errors assigned to the response writer whose status code is `code`.
+// Only stored errors are returned; the entry that reports suppressed errors is never included
+func (r *ResponseWriterInstance) ErrorsWithStatus(code int) []Error {
+	result := []Error{}
+
+	for _, err := range r.errors {
+		if err.StatusCode() == code {
+			result = append(result, err)
+		}
+	}
+
+	return result
 }
 
-// Add error safely adds a new error to the context, converting it to bowtie.Error if appropriate
+// ErrorsJSON marshals the response's errors to a JSON array of their public representations,
+// for example for a debug console. If `includePrivate` is true, each error's private
+// representation, which can contain sensitive data, is included under `private`
+func (r *ResponseWriterInstance) ErrorsJSON(includePrivate bool) ([]byte, error) {
+	return errorsJSON(r.Errors(), includePrivate)
+}
+
+// HasError returns true if at least one error has been assigned to the response writer
+func (r *ResponseWriterInstance) HasError() bool {
+	return len(r.errors) > 0
+}
+
+// Add error safely adds a new error to the context, converting it to bowtie.Error if appropriate.
+// The response's status is set to the error's, unless a status has already been sent
 func (r *ResponseWriterInstance) AddError(err error) {
+	if _, ok := err.(*ProblemError); ok {
+		r.Header().Set("Content-Type", ProblemContentType)
+	}
+
+	status := 500
+
 	if e, ok := err.(Error); ok {
-		r.WriteHeader(e.StatusCode())
-	} else {
-		r.WriteHeader(500)
+		status = e.StatusCode()
+	}
+
+	// A status that has already been sent can't be changed; the error is still recorded, so
+	// that it can be logged
+	if !r.StatusCommitted() {
+		r.WriteHeader(status)
+	}
+
+	if r.maxErrors > 0 && len(r.errors) >= r.maxErrors {
+		r.suppressed += 1
+
+		if status > r.suppressedMax {
+			r.suppressedMax = status
+		}
+
+		return
+	}
+
+	e := NewErrorWithError(err)
+
+	if r.autoCapture && status >= 500 && len(e.StackTrace()) == 0 {
+		if instance, ok := e.(*ErrorInstance); ok {
+			instance.captureCallerStackTrace()
+		}
 	}
 
-	r.errors = append(r.errors, NewErrorWithError(err))
+	r.errors = append(r.errors, e)
 }
 
 // Status returns the HTTP status code of the writer. You can set this by using `WriteHeader()`
@@ -90,11 +340,113 @@ func (r *ResponseWriterInstance) Status() int {
 	return r.status
 }
 
-// WriteHeader writes a status header
+// WriteHeader writes a status header. If the response is buffered, the status is only
+// sent when the request ends
 func (r *ResponseWriterInstance) WriteHeader(status int) {
-	r.ResponseWriter.WriteHeader(status)
 	r.status = status
 	r.written = true
+
+	if r.buffer != nil {
+		r.statusPending = true
+		return
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+	r.statusPending = false
+}
+
+// SetStatus records `code` as the status of the response without writing it to the output
+// stream, so that a later middleware can still change it. The status is written out when the
+// body is first written to or, at the latest, when the request ends. SetStatus has no effect
+// once a status has been written
+func (r *ResponseWriterInstance) SetStatus(code int) {
+	if r.written {
+		return
+	}
+
+	r.status = code
+	r.statusPending = true
+}
+
+// commitStatus writes the status recorded by SetStatus, if it hasn't been written yet
+func (r *ResponseWriterInstance) commitStatus() {
+	if r.statusPending && !r.written {
+		r.WriteHeader(r.status)
+	}
+}
+
+// finish sends the response's status and buffered body, if any. The server calls it
+// when the request ends
+func (r *ResponseWriterInstance) finish() {
+	if r.buffer == nil {
+		r.commitStatus()
+		return
+	}
+
+	p := r.buffer.Bytes()
+	r.buffer = nil
+
+	if len(p) > 0 {
+		r.setContentType(p)
+	}
+
+	r.ResponseWriter.WriteHeader(r.status)
+	r.statusPending = false
+
+	if len(p) > 0 {
+		n, _ := r.ResponseWriter.Write(p)
+		r.bytesWritten += int64(n)
+	}
+}
+
+// SetBuffered enables or disables buffering. While buffering is enabled, the status and
+// body of the response are held in memory and only sent when the request ends, which allows
+// the body to be discarded with DiscardBody. Buffering can only be enabled before anything
+// has been written; disabling it sends whatever has been buffered so far
+func (r *ResponseWriterInstance) SetBuffered(enabled bool) {
+	if !enabled {
+		if r.buffer != nil {
+			r.finish()
+		}
+
+		return
+	}
+
+	if r.buffer == nil && !r.written {
+		r.buffer = &bytes.Buffer{}
+	}
+}
+
+// DiscardBody discards any body that has been buffered so far. It has no effect if the
+// response is not buffered
+func (r *ResponseWriterInstance) DiscardBody() {
+	if r.buffer != nil {
+		r.buffer.Reset()
+	}
+}
+
+// BufferedBody returns the body that has been buffered so far, or nil if the response is
+// not buffered. The returned slice is only valid until the next write
+func (r *ResponseWriterInstance) BufferedBody() []byte {
+	if r.buffer == nil {
+		return nil
+	}
+
+	return r.buffer.Bytes()
+}
+
+// NoContent writes a 204 No Content status. Because 204 responses can't have a body, any
+// subsequent write is discarded and returns http.ErrBodyNotAllowed
+func (r *ResponseWriterInstance) NoContent() {
+	r.WriteHeader(http.StatusNoContent)
+	r.noContent = true
+}
+
+// StatusCommitted returns true if the status and headers have been sent to the client, after
+// which they can no longer be changed. This is the case once a status has been written or any
+// part of the body has been sent, but never while the response is buffered
+func (r *ResponseWriterInstance) StatusCommitted() bool {
+	return (r.written && !r.statusPending) || r.bodyStarted
 }
 
 // Written returns true if any data (including a status code) has been written to the writer's
@@ -103,9 +455,353 @@ func (r *ResponseWriterInstance) Written() bool {
 	return r.written
 }
 
-// Write implements io.Writer and outputs data to the HTTP stream
+// BytesWritten returns the number of bytes of the body that have been sent to the client
+// so far. Bytes held in the buffer of a buffered response are only counted once they are sent
+func (r *ResponseWriterInstance) BytesWritten() int64 {
+	return r.bytesWritten
+}
+
+// Write implements io.Writer and outputs data to the HTTP stream. If an error has already been
+// added to the writer, the data is discarded and ErrWriteAfterError is returned instead
 func (r *ResponseWriterInstance) Write(p []byte) (int, error) {
+	if len(r.errors) > 0 {
+		return 0, ErrWriteAfterError
+	}
+
+	return r.WriteErrorBody(p)
+}
+
+// SetTrailer sets an HTTP trailer that is sent after the body of the response. Trailers
+// should be announced to the client before the body is written; SetTrailer does so
+// automatically if the response's headers haven't been sent yet, so call it early (with an
+// empty value, if necessary) and again once the final value is known
+func (r *ResponseWriterInstance) SetTrailer(key, value string) {
+	header := r.Header()
+	key = http.CanonicalHeaderKey(key)
+
+	announced := false
+
+	for _, declared := range header["Trailer"] {
+		for _, name := range strings.Split(declared, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(name)) == key {
+				announced = true
+			}
+		}
+	}
+
+	if !announced {
+		header.Add("Trailer", key)
+	}
+
+	header.Set(http.TrailerPrefix+key, value)
+}
+
+// SetSignedCookie adds a Set-Cookie header for `cookie`, whose value is signed with `key`
+// using HMAC-SHA256 so that it can be verified with Request.SignedCookie
+func (r *ResponseWriterInstance) SetSignedCookie(cookie *http.Cookie, key []byte) {
+	signed := *cookie
+
+	signed.Value = cookie.Value + "." + base64.RawURLEncoding.EncodeToString(cookieSignature(cookie.Name, cookie.Value, key))
+
+	http.SetCookie(r, &signed)
+}
+
+// WritePage writes `items` in JSON format to the output stream and sets a Link header
+// (RFC 5988) that points to other pages of the collection. `links` maps relation types
+// (like `next`, `prev`, `first`, and `last`) to URLs
+func (r *ResponseWriterInstance) WritePage(items interface{}, links map[string]string) (int, error) {
+	return writePage(r, items, links)
+}
+
+// linkRelationOrder determines the order in which well-known relations appear in Link headers
+var linkRelationOrder = map[string]int{"first": 0, "prev": 1, "next": 2, "last": 3}
+
+// writePage sets the Link header for `links` on `w` and writes `items` as JSON
+func writePage(w ResponseWriter, items interface{}, links map[string]string) (int, error) {
+	rels := make([]string, 0, len(links))
+
+	for rel := range links {
+		rels = append(rels, rel)
+	}
+
+	sort.Slice(rels, func(i, j int) bool {
+		oi, iKnown := linkRelationOrder[rels[i]]
+		oj, jKnown := linkRelationOrder[rels[j]]
+
+		if iKnown != jKnown {
+			return iKnown
+		}
+
+		if iKnown && oi != oj {
+			return oi < oj
+		}
+
+		return rels[i] < rels[j]
+	})
+
+	parts := make([]string, len(rels))
+
+	for index, rel := range rels {
+		parts[index] = fmt.Sprintf(`<%s>; rel="%s"`, links[rel], rel)
+	}
+
+	if len(parts) > 0 {
+		w.Header().Set("Link", strings.Join(parts, ", "))
+	}
+
+	return w.WriteJSON(items)
+}
+
+// WriteCollection writes `items` in JSON format to the output stream and sets the
+// X-Total-Count header to `total`, the size of the whole collection, so that clients can
+// paginate through it. The header is also added to Access-Control-Expose-Headers, so that
+// browsers let cross-origin clients read it
+func (r *ResponseWriterInstance) WriteCollection(items interface{}, total int) (int, error) {
+	return writeCollection(r, items, total)
+}
+
+// addHeaderToken adds `token` to the comma-separated list in the `key` header, unless it is
+// already listed
+func addHeaderToken(header http.Header, key, token string) {
+	for _, value := range header[http.CanonicalHeaderKey(key)] {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), token) {
+				return
+			}
+		}
+	}
+
+	header.Add(key, token)
+}
+
+// writeCollection sets the X-Total-Count header on `w` and writes `items` as JSON
+func writeCollection(w ResponseWriter, items interface{}, total int) (int, error) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	addHeaderToken(w.Header(), "Access-Control-Expose-Headers", "X-Total-Count")
+
+	return w.WriteJSON(items)
+}
+
+// WriteProblem writes an RFC 7807 problem details object built from `status`, `title`, and
+// `detail` (see NewProblemError) with a Content-Type of `application/problem+json`. Unlike
+// AddError, it doesn't record an error, so the body is written even without an error reporter
+func (r *ResponseWriterInstance) WriteProblem(status int, title, detail string) (int, error) {
+	return writeProblem(r, status, title, detail)
+}
+
+// writeProblem writes a problem details object to `w` with the given status
+func writeProblem(w ResponseWriter, status int, title, detail string) (int, error) {
+	p, err := json.Marshal(NewProblemError(status, title, detail))
+
+	if err != nil {
+		w.AddError(err)
+		return 0, err
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+
+	return w.Write(p)
+}
+
+// WriteAttachment copies the contents of `reader` to the output stream as a download named
+// `filename`, setting the Content-Disposition and Content-Type headers accordingly
+func (r *ResponseWriterInstance) WriteAttachment(filename, contentType string, reader io.Reader) (int64, error) {
+	return writeAttachment(r, filename, contentType, reader)
+}
+
+// WriteNDJSON writes `item` in JSON format, followed by a newline, to the output stream and
+// flushes it to the client, so that a collection can be streamed one item at a time. The
+// Content-Type header is set to `application/x-ndjson` if no other type has been set
+func (r *ResponseWriterInstance) WriteNDJSON(item interface{}) (int, error) {
+	return writeNDJSON(r, item)
+}
+
+// writeNDJSON writes `item` to `w` as a single line of JSON and flushes it
+func writeNDJSON(w ResponseWriter, item interface{}) (int, error) {
+	p, err := json.Marshal(item)
+
+	if err != nil {
+		w.AddError(err)
+		return 0, err
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	prepareStream(w)
+
+	// A buffered stream would only reach the client when the request ends
+	w.SetBuffered(false)
+
+	n, err := w.Write(append(p, '\n'))
+
+	if err == nil {
+		w.Flush()
+	}
+
+	return n, err
+}
+
+// prepareStream gets `w` ready for a body of unknown length. Clients that don't understand
+// chunked transfer encoding need the connection to be closed to know that the body has ended
+func prepareStream(w ResponseWriter) {
+	if !w.Chunked() && w.Header().Get("Connection") == "" {
+		w.Header().Set("Connection", "close")
+	}
+}
+
+// SetChunked tells the writer whether the client understands chunked transfer encoding
+func (r *ResponseWriterInstance) SetChunked(enabled bool) {
+	r.noChunked = !enabled
+}
+
+// Chunked returns false if the client doesn't understand chunked transfer encoding
+func (r *ResponseWriterInstance) Chunked() bool {
+	return !r.noChunked
+}
+
+// Flush sends any data written so far to the client, if the underlying writer supports it.
+// It has no effect while the response is buffered
+func (r *ResponseWriterInstance) Flush() {
+	if r.buffer != nil {
+		return
+	}
+
+	r.commitStatus()
+
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// flushChecker is satisfied by response writers that wrap another writer, and can tell whether
+// the writer at the bottom of the chain supports flushing
+type flushChecker interface {
+	canFlush() bool
+}
+
+// canFlush returns true if the writer at the bottom of the chain wrapped by r can be flushed.
+// The server wraps the writers created by its ResponseWriterFactory in another instance, so
+// checking for http.Flusher on the writer that r wraps isn't enough
+func (r *ResponseWriterInstance) canFlush() bool {
+	if inner, ok := r.ResponseWriter.(flushChecker); ok {
+		return inner.canFlush()
+	}
+
+	_, ok := r.ResponseWriter.(http.Flusher)
+
+	return ok
+}
+
+// SSE returns a writer that streams Server-Sent Events to the client. Because each event must
+// be flushed as soon as it is sent, SSE turns buffering off and returns ErrFlushNotSupported
+// if the underlying writer can't be flushed
+func (r *ResponseWriterInstance) SSE() (*SSEWriter, error) {
+	if !r.canFlush() {
+		return nil, ErrFlushNotSupported
+	}
+
+	r.SetBuffered(false)
+
+	return &SSEWriter{w: r}, nil
+}
+
+// writeAttachment sets the headers for a download named `filename` and copies `reader` to `w`
+func writeAttachment(w ResponseWriter, filename, contentType string, reader io.Reader) (int64, error) {
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Type", contentType)
+
+	return io.Copy(w, reader)
+}
+
+// SetResponseTransformer sets a function that transforms any data written as JSON
+func (r *ResponseWriterInstance) SetResponseTransformer(t ResponseTransformer) {
+	r.transformer = t
+}
+
+// SetErrorTransformer sets a function that error reporters apply to each error before output
+func (r *ResponseWriterInstance) SetErrorTransformer(t ErrorTransformer) {
+	r.errTransform = t
+}
+
+// TransformError applies the error transformer to `err`. If no transformer has been set,
+// `err` is returned unchanged
+func (r *ResponseWriterInstance) TransformError(err Error) Error {
+	if r.errTransform == nil {
+		return err
+	}
+
+	return r.errTransform(err)
+}
+
+// SetAutoCaptureStacks enables or disables the automatic capture of stack traces. When
+// enabled, AddError captures a stack trace for any 5xx error that doesn't already have one
+func (r *ResponseWriterInstance) SetAutoCaptureStacks(enabled bool) {
+	r.autoCapture = enabled
+}
+
+// SetSniffJSON enables or disables JSON sniffing. When enabled, a body that looks like JSON
+// (that is, starts with `{` or `[`) is sent with a Content-Type of `application/json` if no
+// other content type has been set
+func (r *ResponseWriterInstance) SetSniffJSON(enabled bool) {
+	r.sniffJSON = enabled
+}
+
+// SetDefaultContentType sets the content type that is sent when a body is written without
+// a Content-Type header, instead of letting net/http guess one. JSON sniffing, if enabled,
+// takes precedence. An empty string restores the standard behavior
+func (r *ResponseWriterInstance) SetDefaultContentType(contentType string) {
+	r.defaultType = contentType
+}
+
+// setContentType sets the Content-Type header of a response whose body starts with `p`, unless
+// one has already been set, using JSON sniffing first and then the default content type
+func (r *ResponseWriterInstance) setContentType(p []byte) {
+	header := r.Header()
+
+	if header.Get("Content-Type") != "" {
+		return
+	}
+
+	if r.sniffJSON && looksLikeJSON(p) {
+		header.Set("Content-Type", "application/json")
+	} else if r.defaultType != "" {
+		header.Set("Content-Type", r.defaultType)
+	}
+}
+
+// looksLikeJSON returns true if the first non-whitespace character of p opens a JSON
+// object or array
+func looksLikeJSON(p []byte) bool {
+	p = bytes.TrimLeft(p, " \t\r\n")
+
+	return len(p) > 0 && (p[0] == '{' || p[0] == '[')
+}
+
+// WriteErrorBody writes `p` to the output stream even if errors have been added to the writer.
+// It is meant to be used by middlewares that report errors to the client
+func (r *ResponseWriterInstance) WriteErrorBody(p []byte) (int, error) {
+	if r.noContent {
+		return 0, http.ErrBodyNotAllowed
+	}
+
+	if r.buffer != nil {
+		return r.buffer.Write(p)
+	}
+
+	if !r.written && len(p) > 0 {
+		r.setContentType(p)
+	}
+
+	r.commitStatus()
+
 	n, err := r.ResponseWriter.Write(p)
+	r.bytesWritten += int64(n)
+
+	// Writing to the underlying writer sends an implicit 200 status if none has been sent yet
+	r.bodyStarted = true
 
 	if err != nil {
 		r.written = true
@@ -140,7 +836,26 @@ func (r *ResponseWriterInstance) WriteStringOrError(s string, err error) (int, e
 // WriteJSON writes data in JSON format to the output stream. The output Content-Type header
 // is also automatically set to `application/json`
 func (r *ResponseWriterInstance) WriteJSON(data interface{}) (int, error) {
-	return r.WriteOrError(json.Marshal(data))
+	return r.WriteJSONWithType("application/json", data)
+}
+
+// WriteJSONWithType works like `WriteJSON`, but sets the output Content-Type header to
+// `contentType` instead. This is useful for emitting vendor-specific media types
+func (r *ResponseWriterInstance) WriteJSONWithType(contentType string, data interface{}) (int, error) {
+	if r.transformer != nil {
+		data = r.transformer(data)
+	}
+
+	p, err := json.Marshal(data)
+
+	if err != nil {
+		r.AddError(err)
+		return 0, err
+	}
+
+	r.Header().Set("Content-Type", contentType)
+
+	return r.Write(p)
 }
 
 // WriteJSONOrError checks if `err` is not nil, in which case it adds it to the context's error
@@ -0,0 +1,75 @@
+package bowtie
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveConditional implements Context.ServeConditional
+func serveConditional(w ResponseWriter, r *Request, modTime time.Time, etag string, write func() error) error {
+	header := w.Header()
+
+	if etag != "" {
+		if !strings.HasSuffix(etag, `"`) {
+			etag = `"` + etag + `"`
+		}
+
+		// A compressed representation has different bytes, and therefore needs a different
+		// entity tag, from the uncompressed one
+		if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+			etag = etag[:len(etag)-1] + "-" + encoding + `"`
+		}
+
+		header.Set("ETag", etag)
+		addHeaderToken(header, "Vary", "Accept-Encoding")
+	}
+
+	if !modTime.IsZero() {
+		header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if (r.Method == "GET" || r.Method == "HEAD") && notModified(r, modTime, etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+
+		w.WriteHeader(http.StatusNotModified)
+
+		return nil
+	}
+
+	return write()
+}
+
+// notModified returns true if the request's conditional headers match the resource
+// identified by `modTime` and `etag`. As required by RFC 7232, If-Modified-Since is
+// ignored when If-None-Match is present.
+func notModified(r *Request, modTime time.Time, etag string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	return !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,61 @@
+package bowtie
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrFlushNotSupported is returned by ResponseWriter.SSE when the underlying writer can't be
+// flushed, which would prevent events from reaching the client as they are sent.
+var ErrFlushNotSupported = errors.New("bowtie: the response writer doesn't support flushing")
+
+// Struct SSEWriter streams Server-Sent Events to the client. You can obtain one by calling
+// ResponseWriter.SSE():
+//
+//  events, err := c.Response().SSE()
+//
+//  if err != nil {
+//      c.Response().AddError(err)
+//      return
+//  }
+//
+//  events.Send("update", `{"count":1}`)
+type SSEWriter struct {
+	w       ResponseWriter
+	started bool
+}
+
+// Send writes an event called `event` whose payload is `data` and flushes it to the client.
+// If `event` is empty, the event has no name, and clients receive it as a `message` event.
+// Data that spans multiple lines is sent as multiple `data:` lines, as required by the
+// protocol. The Content-Type and Cache-Control headers are set on the first call.
+func (s *SSEWriter) Send(event, data string) error {
+	if !s.started {
+		header := s.w.Header()
+
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+
+		prepareStream(s.w)
+
+		s.started = true
+	}
+
+	message := ""
+
+	if event != "" {
+		message += "event: " + event + "\n"
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		message += "data: " + line + "\n"
+	}
+
+	if _, err := s.w.Write([]byte(message + "\n")); err != nil {
+		return err
+	}
+
+	s.w.Flush()
+
+	return nil
+}
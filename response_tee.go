@@ -0,0 +1,162 @@
+package bowtie
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Struct TeeResponseWriter is a ResponseWriter that forwards everything written to the response's
+// body to both a primary writer and a mirror. Status, headers, and errors are tracked by the
+// primary writer; the mirror only receives the body. This is useful, for example, to send a copy
+// of all responses to an audit sink.
+type TeeResponseWriter struct {
+	ResponseWriter
+	mirror      io.Writer
+	transformer ResponseTransformer
+}
+
+var _ ResponseWriter = &TeeResponseWriter{}
+
+// NewTeeResponseWriter creates a new response writer that writes to both `primary` and `mirror`
+func NewTeeResponseWriter(primary ResponseWriter, mirror io.Writer) ResponseWriter {
+	return &TeeResponseWriter{
+		ResponseWriter: primary,
+		mirror:         mirror,
+	}
+}
+
+// Write outputs data to the primary writer and, if successful, copies it to the mirror
+func (t *TeeResponseWriter) Write(p []byte) (int, error) {
+	n, err := t.ResponseWriter.Write(p)
+
+	return t.copyToMirror(p, n, err)
+}
+
+// WriteErrorBody writes `p` to the primary writer even if errors have been added to it and,
+// if successful, copies it to the mirror
+func (t *TeeResponseWriter) WriteErrorBody(p []byte) (int, error) {
+	n, err := t.ResponseWriter.WriteErrorBody(p)
+
+	return t.copyToMirror(p, n, err)
+}
+
+// copyToMirror copies the first `n` bytes of `p` to the mirror, unless the write to the
+// primary writer failed
+func (t *TeeResponseWriter) copyToMirror(p []byte, n int, err error) (int, error) {
+	if err == nil && n > 0 {
+		t.mirror.Write(p[:n])
+	}
+
+	return n, err
+}
+
+// WriteOrError checks if `err` is not nil, in which case it adds it to the primary writer's error
+// list and returns. If `err` is nil, `p` is written to both the primary writer and the mirror
+func (t *TeeResponseWriter) WriteOrError(p []byte, err error) (int, error) {
+	if err != nil {
+		t.AddError(err)
+		return 0, err
+	}
+
+	return t.Write(p)
+}
+
+// WriteString is a convenience method that outputs a string
+func (t *TeeResponseWriter) WriteString(s string) (int, error) {
+	return t.Write([]byte(s))
+}
+
+// WriteStringOrError works like `WriteOrError`, but takes string instead of a byte array
+func (t *TeeResponseWriter) WriteStringOrError(s string, err error) (int, error) {
+	return t.WriteOrError([]byte(s), err)
+}
+
+// WritePage writes `items` in JSON format to both the primary writer and the mirror, and
+// sets a Link header that points to other pages of the collection
+func (t *TeeResponseWriter) WritePage(items interface{}, links map[string]string) (int, error) {
+	return writePage(t, items, links)
+}
+
+// WriteCollection writes `items` in JSON format to both the primary writer and the mirror, and
+// sets the X-Total-Count header to `total`
+func (t *TeeResponseWriter) WriteCollection(items interface{}, total int) (int, error) {
+	return writeCollection(t, items, total)
+}
+
+// WriteProblem writes an RFC 7807 problem details object to both the primary writer and the
+// mirror
+func (t *TeeResponseWriter) WriteProblem(status int, title, detail string) (int, error) {
+	return writeProblem(t, status, title, detail)
+}
+
+// WriteAttachment copies the contents of `reader` to both the primary writer and the mirror as
+// a download named `filename`
+func (t *TeeResponseWriter) WriteAttachment(filename, contentType string, reader io.Reader) (int64, error) {
+	return writeAttachment(t, filename, contentType, reader)
+}
+
+// WriteNDJSON writes `item` as a single line of JSON to both the primary writer and the mirror,
+// and flushes the primary writer
+func (t *TeeResponseWriter) WriteNDJSON(item interface{}) (int, error) {
+	return writeNDJSON(t, item)
+}
+
+// SSE returns a writer that streams Server-Sent Events to both the primary writer and the mirror
+func (t *TeeResponseWriter) SSE() (*SSEWriter, error) {
+	if _, err := t.ResponseWriter.SSE(); err != nil {
+		return nil, err
+	}
+
+	return &SSEWriter{w: t}, nil
+}
+
+// canFlush returns true if the primary writer can be flushed
+func (t *TeeResponseWriter) canFlush() bool {
+	if inner, ok := t.ResponseWriter.(flushChecker); ok {
+		return inner.canFlush()
+	}
+
+	_, ok := t.ResponseWriter.(http.Flusher)
+
+	return ok
+}
+
+// SetResponseTransformer sets a function that transforms any data written as JSON
+func (t *TeeResponseWriter) SetResponseTransformer(transformer ResponseTransformer) {
+	t.transformer = transformer
+}
+
+// WriteJSON writes data in JSON format to both the primary writer and the mirror
+func (t *TeeResponseWriter) WriteJSON(data interface{}) (int, error) {
+	return t.WriteJSONWithType("application/json", data)
+}
+
+// WriteJSONWithType works like `WriteJSON`, but sets the output Content-Type header to
+// `contentType` instead
+func (t *TeeResponseWriter) WriteJSONWithType(contentType string, data interface{}) (int, error) {
+	if t.transformer != nil {
+		data = t.transformer(data)
+	}
+
+	p, err := json.Marshal(data)
+
+	if err != nil {
+		t.AddError(err)
+		return 0, err
+	}
+
+	t.Header().Set("Content-Type", contentType)
+
+	return t.Write(p)
+}
+
+// WriteJSONOrError works like `WriteOrError`, but serializes `data` to JSON
+func (t *TeeResponseWriter) WriteJSONOrError(data interface{}, err error) (int, error) {
+	if err != nil {
+		t.AddError(err)
+		return 0, err
+	}
+
+	return t.WriteJSON(data)
+}
@@ -0,0 +1,638 @@
+package bowtie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseErrorsWithStatus(t *testing.T) {
+	w := NewResponseWriter(newMockWriter())
+
+	if w.HasError() {
+		t.Error("Response writer unexpectedly reports errors before any were added")
+	}
+
+	w.AddError(NewError(503, "Service unavailable"))
+	w.AddError(NewError(400, "Bad request"))
+	w.AddError(errors.New("Generic error"))
+	w.AddError(NewError(503, "Still unavailable"))
+
+	if !w.HasError() {
+		t.Error("Response writer unexpectedly reports no errors")
+	}
+
+	if errs := w.ErrorsWithStatus(503); len(errs) != 2 {
+		t.Errorf("Expected 2 errors with status 503, got %d instead", len(errs))
+	} else if errs[0].Message() != "Service unavailable" || errs[1].Message() != "Still unavailable" {
+		t.Errorf("Unexpected errors with status 503: %#v", errs)
+	}
+
+	if errs := w.ErrorsWithStatus(500); len(errs) != 1 || errs[0].Message() != "Generic error" {
+		t.Errorf("Unexpected errors with status 500: %#v", errs)
+	}
+
+	if errs := w.ErrorsWithStatus(404); len(errs) != 0 {
+		t.Errorf("Expected no errors with status 404, got %d instead", len(errs))
+	}
+}
+
+func TestResponseWriteJSONWithType(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	if _, err := w.WriteJSONWithType("application/vnd.myapp+json", map[string]interface{}{"test": 123}); err != nil {
+		t.Fatalf("Unable to write JSON: %s", err)
+	}
+
+	if ct := m.header.Get("Content-Type"); ct != "application/vnd.myapp+json" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	if string(m.written) != `{"test":123}` {
+		t.Errorf("Unexpected output: %s", m.written)
+	}
+}
+
+func TestResponseWriteAfterError(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	w.AddError(NewError(400, "Bad request"))
+
+	if n, err := w.WriteString("partial output"); n != 0 || err != ErrWriteAfterError {
+		t.Errorf("Expected write after error to be discarded, got (%d, %v) instead", n, err)
+	}
+
+	if len(m.written) != 0 {
+		t.Errorf("Unexpected output after error: %s", m.written)
+	}
+
+	if m.status != 400 {
+		t.Errorf("Expected status 400, got %d instead", m.status)
+	}
+
+	if _, err := w.WriteErrorBody([]byte("error output")); err != nil {
+		t.Errorf("Unable to write error body: %s", err)
+	}
+
+	if string(m.written) != "error output" {
+		t.Errorf("Unexpected error output: %s", m.written)
+	}
+}
+
+func TestResponseErrorsJSON(t *testing.T) {
+	w := NewResponseWriter(newMockWriter())
+
+	e := NewError(http.StatusConflict, "Duplicate user")
+	e.SetData("users_email_idx")
+
+	w.AddError(e)
+	w.AddError(errors.New("connection refused"))
+
+	p, err := w.ErrorsJSON(false)
+
+	if err != nil {
+		t.Fatalf("Unable to marshal errors: %s", err)
+	}
+
+	if string(p) != `[{"message":"Duplicate user","statusCode":409},{"message":"An server error has occurred.","statusCode":500}]` {
+		t.Errorf("Unexpected public errors: %s", p)
+	}
+
+	p, err = w.ErrorsJSON(true)
+
+	if err != nil {
+		t.Fatalf("Unable to marshal errors: %s", err)
+	}
+
+	errs := []map[string]interface{}{}
+
+	if err := json.Unmarshal(p, &errs); err != nil {
+		t.Fatalf("Unable to decode errors %s: %s", p, err)
+	}
+
+	if len(errs) != 2 || errs[1]["message"] != "An server error has occurred." {
+		t.Fatalf("Unexpected private errors: %s", p)
+	}
+
+	first, _ := errs[0]["private"].(map[string]interface{})
+	second, _ := errs[1]["private"].(map[string]interface{})
+
+	if first["data"] != "users_email_idx" || second["message"] != "connection refused" {
+		t.Errorf("Expected private data in errors, got %s instead", p)
+	}
+}
+
+func TestTeeResponseWriter(t *testing.T) {
+	m := newMockWriter()
+	mirror := &bytes.Buffer{}
+
+	w := NewTeeResponseWriter(NewResponseWriter(m), mirror)
+
+	w.WriteString("Hello ")
+	w.WriteJSON(map[string]interface{}{"test": 123})
+
+	if string(m.written) != `Hello {"test":123}` {
+		t.Errorf("Unexpected client output: %s", m.written)
+	}
+
+	if mirror.String() != string(m.written) {
+		t.Errorf("Mirror output %s doesn't match client output %s", mirror.String(), m.written)
+	}
+
+	if w.Status() != 200 {
+		t.Errorf("Expected status 200, got %d instead", w.Status())
+	}
+}
+
+func TestResponseWriteAttachment(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	n, err := w.WriteAttachment(`report "2015".csv`, "text/csv", strings.NewReader("a,b\n1,2\n"))
+
+	if err != nil {
+		t.Fatalf("Unable to write attachment: %s", err)
+	}
+
+	if n != 8 || string(m.written) != "a,b\n1,2\n" {
+		t.Errorf("Unexpected attachment output (%d bytes): %s", n, m.written)
+	}
+
+	if cd := m.header.Get("Content-Disposition"); cd != `attachment; filename="report \"2015\".csv"` {
+		t.Errorf("Unexpected Content-Disposition header: %s", cd)
+	}
+
+	if ct := m.header.Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+}
+
+func TestResponseSniffJSON(t *testing.T) {
+	expect := func(body, contentType string) {
+		m := newMockWriter()
+		w := NewResponseWriter(m)
+
+		w.SetSniffJSON(true)
+		w.WriteString(body)
+
+		if ct := m.header.Get("Content-Type"); ct != contentType {
+			t.Errorf("Expected Content-Type %q for %q, got %q instead", contentType, body, ct)
+		}
+	}
+
+	expect(`{"test":123}`, "application/json")
+	expect(`  [1, 2, 3]`, "application/json")
+	expect(`Hello`, "")
+
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	w.SetSniffJSON(true)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteString(`{"test":123}`)
+
+	if ct := m.header.Get("Content-Type"); ct != "text/plain" {
+		t.Errorf("Sniffing unexpectedly overrode an explicit content type: %s", ct)
+	}
+}
+
+func TestResponseDefaultContentType(t *testing.T) {
+	expect := func(contentType, body, expected string) {
+		m := newMockWriter()
+		w := NewResponseWriter(m)
+
+		w.SetSniffJSON(true)
+		w.SetDefaultContentType("text/plain; charset=utf-8")
+
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+
+		w.WriteString(body)
+
+		if ct := m.header.Get("Content-Type"); ct != expected {
+			t.Errorf("Expected Content-Type %q for %q, got %q instead", expected, body, ct)
+		}
+	}
+
+	expect("", "plain text", "text/plain; charset=utf-8")
+	expect("", `{"test":123}`, "application/json")
+	expect("text/csv", "a,b", "text/csv")
+
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	w.SetDefaultContentType("text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusNoContent)
+
+	if ct := m.header.Get("Content-Type"); ct != "" {
+		t.Errorf("Unexpected Content-Type for a response without a body: %s", ct)
+	}
+}
+
+func TestResponseSetStatus(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	w.SetStatus(http.StatusAccepted)
+
+	if w.Written() || m.status != 0 {
+		t.Error("SetStatus unexpectedly wrote the status")
+	}
+
+	if w.Status() != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d instead", http.StatusAccepted, w.Status())
+	}
+
+	w.SetStatus(http.StatusCreated)
+	w.WriteString("created")
+
+	if m.status != http.StatusCreated || w.Status() != http.StatusCreated {
+		t.Errorf("Expected committed status %d, got %d instead", http.StatusCreated, m.status)
+	}
+
+	w.SetStatus(http.StatusTeapot)
+
+	if w.Status() != http.StatusCreated {
+		t.Errorf("SetStatus unexpectedly changed a committed status to %d", w.Status())
+	}
+}
+
+func TestResponseBuffered(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m).(*ResponseWriterInstance)
+
+	w.SetBuffered(true)
+	w.WriteHeader(http.StatusCreated)
+	w.WriteString("discarded")
+
+	if m.status != 0 || len(m.written) != 0 {
+		t.Errorf("Buffered response unexpectedly sent data: %d %s", m.status, m.written)
+	}
+
+	w.DiscardBody()
+	w.WriteString("kept")
+	w.finish()
+
+	if m.status != http.StatusCreated || string(m.written) != "kept" {
+		t.Errorf("Unexpected buffered output: %d %s", m.status, m.written)
+	}
+}
+
+func TestResponseWritePage(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	_, err := w.WritePage([]int{4, 5, 6}, map[string]string{
+		"last":  "/items?page=10",
+		"next":  "/items?page=3",
+		"prev":  "/items?page=1",
+		"first": "/items?page=1",
+	})
+
+	if err != nil {
+		t.Fatalf("Unable to write page: %s", err)
+	}
+
+	if string(m.written) != "[4,5,6]" {
+		t.Errorf("Unexpected page output: %s", m.written)
+	}
+
+	expected := `</items?page=1>; rel="first", </items?page=1>; rel="prev", </items?page=3>; rel="next", </items?page=10>; rel="last"`
+
+	if link := m.header.Get("Link"); link != expected {
+		t.Errorf("Unexpected Link header: %s", link)
+	}
+}
+
+func TestResponseSetTrailer(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().SetTrailer("X-Checksum", "")
+		c.Response().WriteString("streamed body")
+		c.Response().SetTrailer("X-Checksum", "abc123")
+	})
+
+	ss := httptest.NewServer(s)
+	defer ss.Close()
+
+	res, err := http.Get(ss.URL)
+
+	if err != nil {
+		t.Fatalf("Unable to run test server: %s", err)
+	}
+
+	defer res.Body.Close()
+
+	body, _ := ioutil.ReadAll(res.Body)
+
+	if string(body) != "streamed body" {
+		t.Errorf("Unexpected body: %s", body)
+	}
+
+	if trailer := res.Trailer.Get("X-Checksum"); trailer != "abc123" {
+		t.Errorf("Unexpected trailer: %q", trailer)
+	}
+}
+
+func TestResponseWriteNDJSONBuffered(t *testing.T) {
+	s := NewServer()
+
+	s.BufferResponses = true
+
+	w := httptest.NewRecorder()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().WriteNDJSON(map[string]int{"id": 1})
+
+		if w.Body.String() != "{\"id\":1}\n" {
+			t.Errorf("Expected the first item to be sent right away, got %q instead", w.Body.String())
+		}
+
+		c.Response().WriteNDJSON(map[string]int{"id": 2})
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Body.String() != "{\"id\":1}\n{\"id\":2}\n" || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("Unexpected response: %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+}
+
+func TestResponseWriteNDJSON(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		for index := 1; index <= 3; index++ {
+			c.Response().WriteNDJSON(map[string]int{"id": index})
+		}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	if !w.Flushed {
+		t.Error("Expected the response to be flushed")
+	}
+
+	scanner := bufio.NewScanner(w.Body)
+	count := 0
+
+	for scanner.Scan() {
+		var item map[string]int
+
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			t.Fatalf("Unable to parse line %q: %s", scanner.Text(), err)
+		}
+
+		count += 1
+
+		if item["id"] != count {
+			t.Errorf("Expected item %d, got %d instead", count, item["id"])
+		}
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 items, got %d instead", count)
+	}
+}
+
+func TestResponseNoContent(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().NoContent()
+
+		if _, err := c.Response().WriteString("oops"); err != http.ErrBodyNotAllowed {
+			t.Errorf("Expected http.ErrBodyNotAllowed, got %v instead", err)
+		}
+
+		if !c.Response().Written() {
+			t.Error("Expected the response to be marked as written")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/", nil)
+
+	s.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNoContent, w.Code)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q instead", w.Body.String())
+	}
+}
+
+func TestResponseMaxErrors(t *testing.T) {
+	w := NewResponseWriter(newMockWriter())
+
+	for index := 0; index < DefaultMaxErrors+5; index++ {
+		w.AddError(NewError(400, "Error %d", index))
+	}
+
+	errs := w.Errors()
+
+	if len(errs) != DefaultMaxErrors+1 {
+		t.Fatalf("Expected %d errors, got %d instead", DefaultMaxErrors+1, len(errs))
+	}
+
+	if last := errs[DefaultMaxErrors]; last.Message() != "5 more errors suppressed" || last.StatusCode() != 400 {
+		t.Errorf("Unexpected suppression entry: %d %s", last.StatusCode(), last.Message())
+	}
+
+	w = NewResponseWriter(newMockWriter())
+	w.SetMaxErrors(2)
+
+	w.AddError(NewError(400, "First"))
+	w.AddError(NewError(400, "Second"))
+	w.AddError(NewError(404, "Third"))
+
+	if errs := w.Errors(); len(errs) != 3 || errs[2].Message() != "1 more errors suppressed" || errs[2].StatusCode() != 404 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	if errs := w.ErrorsWithStatus(404); len(errs) != 0 {
+		t.Errorf("Expected the suppression entry to be left out, got %v instead", errs)
+	}
+
+	if errs := w.ErrorsWithStatus(400); len(errs) != 2 {
+		t.Errorf("Expected 2 errors with status 400, got %d instead", len(errs))
+	}
+}
+
+func TestResponseWriteCollection(t *testing.T) {
+	w := newMockWriter()
+	r := NewResponseWriter(w)
+
+	r.Header().Set("Access-Control-Expose-Headers", "ETag")
+
+	r.WriteCollection([]string{"a", "b"}, 42)
+
+	if string(w.written) != `["a","b"]` {
+		t.Errorf("Unexpected body: %s", w.written)
+	}
+
+	if total := w.header.Get("X-Total-Count"); total != "42" {
+		t.Errorf("Unexpected X-Total-Count header: %s", total)
+	}
+
+	if exposed := w.header["Access-Control-Expose-Headers"]; len(exposed) != 2 || exposed[1] != "X-Total-Count" {
+		t.Errorf("Unexpected Access-Control-Expose-Headers header: %v", exposed)
+	}
+}
+
+func TestResponseWriteProblem(t *testing.T) {
+	m := newMockWriter()
+	w := NewResponseWriter(m)
+
+	if _, err := w.WriteProblem(http.StatusTooManyRequests, "", "Try again in 30 seconds"); err != nil {
+		t.Fatalf("Unable to write problem: %s", err)
+	}
+
+	if m.status != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d instead", http.StatusTooManyRequests, m.status)
+	}
+
+	if ct := m.header.Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	problem := map[string]interface{}{}
+
+	if err := json.Unmarshal(m.written, &problem); err != nil {
+		t.Fatalf("Unable to decode problem %s: %s", m.written, err)
+	}
+
+	if problem["status"] != float64(429) || problem["title"] != "Too Many Requests" || problem["detail"] != "Try again in 30 seconds" {
+		t.Errorf("Unexpected problem: %s", m.written)
+	}
+
+	if w.HasError() {
+		t.Error("WriteProblem unexpectedly recorded an error")
+	}
+}
+
+func TestResponseSSE(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		events, err := c.Response().SSE()
+
+		if err != nil {
+			t.Fatalf("Unable to start event stream: %s", err)
+		}
+
+		events.Send("greeting", "hello")
+		events.Send("", "multi\nline")
+	})
+
+	ss := httptest.NewServer(s)
+	defer ss.Close()
+
+	res, err := http.Get(ss.URL)
+
+	if err != nil {
+		t.Fatalf("Unable to run test server: %s", err)
+	}
+
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected Content-Type header: %s", ct)
+	}
+
+	if cc := res.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Unexpected Cache-Control header: %s", cc)
+	}
+
+	events := []string{}
+	event := ""
+	scanner := bufio.NewScanner(res.Body)
+
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			event += line + "|"
+		} else {
+			events = append(events, event)
+			event = ""
+		}
+	}
+
+	if len(events) != 2 || events[0] != "event: greeting|data: hello|" || events[1] != "data: multi|data: line|" {
+		t.Errorf("Unexpected events: %q", events)
+	}
+}
+
+func TestResponseSSERequiresFlusher(t *testing.T) {
+	if _, err := NewResponseWriter(newMockWriter()).SSE(); err != ErrFlushNotSupported {
+		t.Errorf("Expected ErrFlushNotSupported, got %v instead", err)
+	}
+
+	var err error
+
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		_, err = c.Response().SSE()
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	s.ServeHTTP(newMockWriter(), req)
+
+	if err != ErrFlushNotSupported {
+		t.Errorf("Expected ErrFlushNotSupported from a server, got %v instead", err)
+	}
+}
+
+func TestResponseStreamingHTTP10(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		if c.Request().ProtoAtLeast(1, 1) != (c.Request().ProtoMinor == 1) {
+			t.Errorf("Unexpected ProtoAtLeast result for %s", c.Request().Proto)
+		}
+
+		c.Response().WriteNDJSON(map[string]int{"id": 1})
+	})
+
+	expect := func(major, minor int, connection string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.ProtoMajor, r.ProtoMinor = major, minor
+
+		w := httptest.NewRecorder()
+
+		s.ServeHTTP(w, r)
+
+		if w.Header().Get("Connection") != connection {
+			t.Errorf("Expected Connection %q for HTTP/%d.%d, got %q instead", connection, major, minor, w.Header().Get("Connection"))
+		}
+
+		if w.Body.String() != "{\"id\":1}\n" {
+			t.Errorf("Unexpected body for HTTP/%d.%d: %q", major, minor, w.Body.String())
+		}
+	}
+
+	expect(1, 0, "close")
+	expect(1, 1, "")
+}
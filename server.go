@@ -6,7 +6,14 @@
 package bowtie
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Middleware is a function that encapsulate a Bowtie middleware. It receives an execution
@@ -14,6 +21,10 @@ import (
 // end of the request (useful for logging, error handling, etc.)
 type Middleware func(c Context, next func())
 
+// PhaseMiddleware is the name of the phase (see Context.Phases) during which the server runs
+// a request's middleware chain. It is complete by the time OnResponse observers are called
+const PhaseMiddleware = "middleware"
+
 // Interface MiddleProvider can be implemented by structs that want to offer both
 // a middleware and a context factory. They can be installed onto a server by calling
 // AddMiddlewareProvider()
@@ -25,36 +36,132 @@ type MiddlewareProvider interface {
 // Struct Server is a Bowtie server. It provides a handler compatible with http.ListenAndServe
 // that creates a context and executes any attached middleware.
 type Server struct {
+	// Counters are kept first so they stay 64-bit aligned for atomic access
+	requests  int64
+	inFlight  int64
+	panics    int64
+	responses [5]int64
+
 	middlewares           []Middleware
+	middlewareNames       []string
 	contextFactories      []ContextFactory
+	responseObservers     []func(c Context)
 	ResponseWriterFactory ResponseWriterFactory
+	// ResponseTransformer, if set, is applied to all the data written through WriteJSON,
+	// for example to wrap every payload in an envelope
+	ResponseTransformer ResponseTransformer
+	// ErrorTransformer, if set, is applied by error reporters to each error before it is output,
+	// for example to redact internal messages
+	ErrorTransformer ErrorTransformer
+	// TrustedProxies is a list of CIDRs (or IP addresses) of the proxies whose forwarding
+	// headers can be trusted by helpers like Request.ClientIP() and Request.Scheme(). It is
+	// parsed once, when the first request is handled, so it must be set before then. An entry
+	// that can't be parsed causes a panic (or an error from ListenAndServe)
+	TrustedProxies []string
+	// BufferResponses, if true, causes responses to be held in memory until the request ends,
+	// so that error reporters can replace any body written before an error occurred
+	BufferResponses bool
+	// SniffJSON, if true, causes bodies that look like JSON to be sent with a Content-Type of
+	// `application/json` when handlers don't set one themselves
+	SniffJSON bool
+	// DefaultContentType, if set, is sent as the Content-Type of bodies written without one,
+	// instead of the type guessed by net/http
+	DefaultContentType string
+	// AutoCaptureStacks, if true, causes a stack trace to be captured for every 5xx error added
+	// to a response, so that logs always include one for server errors
+	AutoCaptureStacks bool
+	// IDGenerator is used by Context.NewID() to generate unique identifiers
+	IDGenerator IDGenerator
+	// HTTPServerConfig holds the settings applied to the http.Server created by ListenAndServe
+	HTTPServerConfig HTTPServerConfig
+	trustedNetworks  []*net.IPNet
+	trustedErr       error
+	trustedOnce      sync.Once
+	background       sync.WaitGroup
+	httpServer       *http.Server
+	httpServerMutex  sync.Mutex
+	shutDown         bool
+}
+
+// Struct HTTPServerConfig contains the settings that Server applies to the underlying
+// http.Server when you call ListenAndServe. See net/http for the meaning of each field.
+type HTTPServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// DefaultHTTPServerConfig returns a configuration with conservative timeouts that protect
+// the server against slow clients
+func DefaultHTTPServerConfig() HTTPServerConfig {
+	return HTTPServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// Struct ServerStats is a snapshot of the counters maintained by a server. Responses are
+// counted by status class; ResponsesByClass[0] holds 1xx responses, ResponsesByClass[1]
+// 2xx responses, and so on. Panics counts the panics reported through Context.RecordPanic,
+// for example by the Recovery middleware.
+type ServerStats struct {
+	Requests         int64
+	InFlight         int64
+	Panics           int64
+	ResponsesByClass [5]int64
 }
 
 // NewServer initializes and returns a new Server instance.
 func NewServer() *Server {
 	return &Server{
 		middlewares:           []Middleware{},
+		middlewareNames:       []string{},
 		contextFactories:      []ContextFactory{},
 		ResponseWriterFactory: NewResponseWriter,
+		IDGenerator:           NewID,
+		HTTPServerConfig:      DefaultHTTPServerConfig(),
 	}
 }
 
 // SetContextFactory changes the context factory used by the server. This allows you
 // to create your own Context structs and use them inside your apps.
+//
+// Adding a nil factory causes a panic that names the factory's position, rather than a
+// nil-pointer dereference the first time a request is served.
 func (s *Server) AddContextFactory(value ContextFactory) {
+	if value == nil {
+		panic(fmt.Sprintf("bowtie: context factory #%d is nil", len(s.contextFactories)))
+	}
+
 	s.contextFactories = append(s.contextFactories, value)
 }
 
 // AddMiddleware adds a new middleware handler. Handlers are executed in the order
 // in which they are added to the server
 func (s *Server) AddMiddleware(f Middleware) {
+	s.AddNamedMiddleware("", f)
+}
+
+// AddNamedMiddleware adds a new middleware handler that can later be found and removed
+// by `name`
+func (s *Server) AddNamedMiddleware(name string, f Middleware) {
 	s.middlewares = append(s.middlewares, f)
+	s.middlewareNames = append(s.middlewareNames, name)
 }
 
 // AddMiddlewareProvider registers a new middleware provider
 func (s *Server) AddMiddlewareProvider(p MiddlewareProvider) {
+	s.AddNamedMiddlewareProvider("", p)
+}
+
+// AddNamedMiddlewareProvider registers a new middleware provider whose middleware can later
+// be found and removed by `name`
+func (s *Server) AddNamedMiddlewareProvider(name string, p MiddlewareProvider) {
 	if mw := p.Middleware(); mw != nil {
-		s.middlewares = append(s.middlewares, mw)
+		s.AddNamedMiddleware(name, mw)
 	}
 
 	if cf := p.ContextFactory(); cf != nil {
@@ -62,11 +169,117 @@ func (s *Server) AddMiddlewareProvider(p MiddlewareProvider) {
 	}
 }
 
+// OnResponse registers `observer` to be called at the end of every request, once the response
+// has been sent and after any function registered with Context.Defer has run. Observers can
+// inspect the final status, size, and errors of the response, for example for auditing or
+// metrics, but must not write to it. Observers run in the order in which they are registered.
+//
+// Context.Phases() reports how long the request spent routing, running its handlers, and
+// running the middleware chain as a whole
+func (s *Server) OnResponse(observer func(c Context)) {
+	s.responseObservers = append(s.responseObservers, observer)
+}
+
+// MiddlewareNames returns the names of the middlewares installed on the server, in the order
+// in which they run. Middlewares added without a name are reported as empty strings
+func (s *Server) MiddlewareNames() []string {
+	return append([]string{}, s.middlewareNames...)
+}
+
+// Struct MiddlewareInfo describes a middleware installed on a server
+type MiddlewareInfo struct {
+	Position int    // The zero-based position of the middleware in the execution chain
+	Name     string // The name the middleware was registered with, if any
+}
+
+// Middlewares returns a description of the middlewares installed on the server, in the order
+// in which they run. This is meant for diagnostics; the result can't be used to alter the chain
+func (s *Server) Middlewares() []MiddlewareInfo {
+	result := make([]MiddlewareInfo, len(s.middlewareNames))
+
+	for index, name := range s.middlewareNames {
+		result[index] = MiddlewareInfo{
+			Position: index,
+			Name:     name,
+		}
+	}
+
+	return result
+}
+
+// RemoveMiddleware removes the middleware registered with `name` and returns true, or
+// returns false if no such middleware exists. The context factory of a middleware provider,
+// if any, is left in place. Like the other methods that configure the server, RemoveMiddleware
+// is not safe to call while the server is handling requests
+func (s *Server) RemoveMiddleware(name string) bool {
+	for index, n := range s.middlewareNames {
+		if n == name && name != "" {
+			s.middlewares = append(s.middlewares[:index:index], s.middlewares[index+1:]...)
+			s.middlewareNames = append(s.middlewareNames[:index:index], s.middlewareNames[index+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// trustedProxies returns the networks listed in TrustedProxies, which are parsed the first time
+// it is called
+func (s *Server) trustedProxies() ([]*net.IPNet, error) {
+	s.trustedOnce.Do(func() {
+		s.trustedNetworks, s.trustedErr = parseTrustedProxies(s.TrustedProxies)
+	})
+
+	return s.trustedNetworks, s.trustedErr
+}
+
 // NewContext creates a new basic server context. You should not need to call this
 // except for testing purposes. Instead, you should extend the server context
 // with your struct and provide a context factory to the server
 func (s *Server) NewContext(r *http.Request, w http.ResponseWriter) Context {
-	c := NewContext(r, s.ResponseWriterFactory(w))
+	c := newContext(r, s.ResponseWriterFactory(w), &s.background)
+	c.panics = &s.panics
+
+	if s.IDGenerator != nil {
+		c.newID = s.IDGenerator
+	}
+
+	trusted, err := s.trustedProxies()
+
+	if err != nil {
+		panic(err)
+	}
+
+	c.r.trustedProxies = trusted
+
+	if s.ResponseTransformer != nil {
+		c.Response().SetResponseTransformer(s.ResponseTransformer)
+	}
+
+	if !c.r.ProtoAtLeast(1, 1) {
+		c.Response().SetChunked(false)
+	}
+
+	if s.ErrorTransformer != nil {
+		c.Response().SetErrorTransformer(s.ErrorTransformer)
+	}
+
+	if s.SniffJSON {
+		c.Response().SetSniffJSON(true)
+	}
+
+	if s.DefaultContentType != "" {
+		c.Response().SetDefaultContentType(s.DefaultContentType)
+	}
+
+	if s.BufferResponses {
+		c.Response().SetBuffered(true)
+	}
+
+	if s.AutoCaptureStacks {
+		c.Response().SetAutoCaptureStacks(true)
+	}
 
 	for _, factory := range s.contextFactories {
 		factory(c)
@@ -75,9 +288,66 @@ func (s *Server) NewContext(r *http.Request, w http.ResponseWriter) Context {
 	return c
 }
 
+// deferrer is satisfied by contexts (including those that embed ContextInstance) that can run
+// the functions registered with Context.Defer
+type deferrer interface {
+	runDeferred()
+}
+
+// startResetter is satisfied by contexts (including those that embed ContextInstance) whose
+// running time can be restarted when they are reused for a new request
+type startResetter interface {
+	resetStart()
+}
+
+// responseFinisher is satisfied by response writers (including those that embed
+// ResponseWriterInstance) that need to send a pending status or buffered body when
+// the request ends
+type responseFinisher interface {
+	finish()
+}
+
 // Run is the server's main entry point. It executes each middleware in sequence
-// until one of them causes data to be written to the output
+// until one of them causes data to be written to the output, and then runs any
+// function registered with Context.Defer
 func (s *Server) Run(c Context) {
+	if r, ok := c.(startResetter); ok {
+		r.resetStart()
+	}
+
+	atomic.AddInt64(&s.requests, 1)
+	atomic.AddInt64(&s.inFlight, 1)
+
+	defer func() {
+		for _, observer := range s.responseObservers {
+			observer(c)
+		}
+
+		atomic.AddInt64(&s.inFlight, -1)
+
+		if class := c.Response().Status() / 100; class >= 1 && class <= 5 {
+			atomic.AddInt64(&s.responses[class-1], 1)
+		}
+	}()
+
+	// Registered before the response is finished, so that the phase is complete by the time
+	// the response observers run
+	defer c.StartPhase(PhaseMiddleware)()
+
+	if f, ok := c.Response().(responseFinisher); ok {
+		defer f.finish()
+	}
+
+	if d, ok := c.(deferrer); ok {
+		defer d.runDeferred()
+	}
+
+	// A context factory failed; don't run any middleware, but still tell the client why
+	if c.Response().HasError() {
+		writeFactoryErrors(c.Response())
+		return
+	}
+
 	mwIndex := -1
 	mwCount := len(s.middlewares)
 
@@ -103,6 +373,37 @@ func (s *Server) Run(c Context) {
 	next()
 }
 
+// writeFactoryErrors outputs the errors added by a failed context factory as a JSON array, in
+// the same format used by the ErrorReporter middleware, which doesn't get a chance to run.
+// Server errors are replaced by a generic one, so that their details aren't leaked
+func writeFactoryErrors(res ResponseWriter) {
+	errs := []Error{}
+	maxStatus := 0
+
+	for _, err := range res.Errors() {
+		err = res.TransformError(err)
+
+		if err.StatusCode() < 500 {
+			errs = append(errs, err)
+		} else if err.StatusCode() > maxStatus {
+			maxStatus = err.StatusCode()
+		}
+	}
+
+	if maxStatus >= 500 {
+		errs = append(errs, NewError(500, "A server error has occurred"))
+	}
+
+	p, err := json.Marshal(errs)
+
+	if err != nil {
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteErrorBody(p)
+}
+
 // ServeHTTP handles requests and can be used as a handler for http.Server
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Body != nil {
@@ -111,3 +412,89 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	s.Run(s.NewContext(r, w))
 }
+
+// Stats returns a snapshot of the server's request counters. It is safe to call
+// concurrently with requests being served.
+func (s *Server) Stats() ServerStats {
+	result := ServerStats{
+		Requests: atomic.LoadInt64(&s.requests),
+		InFlight: atomic.LoadInt64(&s.inFlight),
+		Panics:   atomic.LoadInt64(&s.panics),
+	}
+
+	for index := range s.responses {
+		result.ResponsesByClass[index] = atomic.LoadInt64(&s.responses[index])
+	}
+
+	return result
+}
+
+// HTTPServer creates an http.Server that listens on `addr`, uses s as its handler, and is
+// configured according to s.HTTPServerConfig
+func (s *Server) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadTimeout:       s.HTTPServerConfig.ReadTimeout,
+		ReadHeaderTimeout: s.HTTPServerConfig.ReadHeaderTimeout,
+		WriteTimeout:      s.HTTPServerConfig.WriteTimeout,
+		IdleTimeout:       s.HTTPServerConfig.IdleTimeout,
+		MaxHeaderBytes:    s.HTTPServerConfig.MaxHeaderBytes,
+	}
+}
+
+// ListenAndServe listens on `addr` and serves requests using an http.Server configured
+// according to s.HTTPServerConfig. Like http.Server.ListenAndServe, it returns
+// http.ErrServerClosed after Shutdown has been called, even if Shutdown is called before the
+// server has started listening.
+func (s *Server) ListenAndServe(addr string) error {
+	if _, err := s.trustedProxies(); err != nil {
+		return err
+	}
+
+	s.httpServerMutex.Lock()
+
+	if s.shutDown {
+		s.httpServerMutex.Unlock()
+		return http.ErrServerClosed
+	}
+
+	httpServer := s.HTTPServer(addr)
+	s.httpServer = httpServer
+
+	s.httpServerMutex.Unlock()
+
+	return httpServer.ListenAndServe()
+}
+
+// Shutdown gracefully stops the server started by ListenAndServe, if any, and then waits for
+// all the background work started through Context.Go to complete. If ctx expires first,
+// Shutdown returns its error instead.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.httpServerMutex.Lock()
+
+	s.shutDown = true
+	httpServer := s.httpServer
+
+	s.httpServerMutex.Unlock()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
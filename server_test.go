@@ -1,7 +1,10 @@
 package bowtie
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -75,3 +78,443 @@ func TestServer(t *testing.T) {
 		t.Error("Middlewares doen't seem to be run in the proper order")
 	}
 }
+
+func TestServerShutdownWaitsForBackgroundWork(t *testing.T) {
+	s := NewServer()
+
+	var done int32
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Go(func() {
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&done, 1)
+		})
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error during shutdown: %s", err)
+	}
+
+	if atomic.LoadInt32(&done) != 1 {
+		t.Error("Shutdown returned before background work completed")
+	}
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Go(func() {
+			time.Sleep(time.Second)
+		})
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected shutdown to time out, got %v instead", err)
+	}
+}
+
+func TestServerShutdownListenAndServe(t *testing.T) {
+	// Shutdown may run before or after ListenAndServe has started listening; either way, the
+	// server must stop (and go test -race must not report the two racing)
+	for _, delay := range []time.Duration{0, 20 * time.Millisecond} {
+		s := NewServer()
+		errs := make(chan error, 1)
+
+		go func() {
+			errs <- s.ListenAndServe("127.0.0.1:0")
+		}()
+
+		time.Sleep(delay)
+
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Unexpected error during shutdown: %s", err)
+		}
+
+		select {
+		case err := <-errs:
+			if err != http.ErrServerClosed {
+				t.Errorf("Expected %v after %s, got %v instead", http.ErrServerClosed, delay, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("ListenAndServe was still running a second after Shutdown (delay %s)", delay)
+		}
+	}
+}
+
+func TestServerStats(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		if c.Request().URL.Path == "/missing" {
+			c.Response().AddError(NewError(404, "Not found"))
+			return
+		}
+
+		c.Response().WriteString("ok")
+	})
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		r, _ := http.NewRequest("GET", path, nil)
+
+		s.ServeHTTP(newMockWriter(), r)
+	}
+
+	stats := s.Stats()
+
+	if stats.Requests != 3 {
+		t.Errorf("Expected 3 requests, got %d instead", stats.Requests)
+	}
+
+	if stats.InFlight != 0 {
+		t.Errorf("Expected no requests in flight, got %d instead", stats.InFlight)
+	}
+
+	if stats.ResponsesByClass[1] != 2 || stats.ResponsesByClass[3] != 1 {
+		t.Errorf("Unexpected response counts: %#v", stats.ResponsesByClass)
+	}
+}
+
+func TestServerHTTPServerConfig(t *testing.T) {
+	s := NewServer()
+
+	if s.HTTPServerConfig.ReadHeaderTimeout == 0 || s.HTTPServerConfig.MaxHeaderBytes == 0 {
+		t.Errorf("Expected secure defaults, got %#v instead", s.HTTPServerConfig)
+	}
+
+	s.HTTPServerConfig.ReadHeaderTimeout = 2 * time.Second
+	s.HTTPServerConfig.IdleTimeout = 30 * time.Second
+	s.HTTPServerConfig.MaxHeaderBytes = 4096
+
+	hs := s.HTTPServer(":8000")
+
+	if hs.Addr != ":8000" || hs.Handler != s {
+		t.Errorf("Unexpected address or handler on underlying server: %#v", hs)
+	}
+
+	if hs.ReadHeaderTimeout != 2*time.Second || hs.IdleTimeout != 30*time.Second || hs.MaxHeaderBytes != 4096 {
+		t.Errorf("Configuration not applied to underlying server: %#v", hs)
+	}
+}
+
+func TestServerDeferredCleanups(t *testing.T) {
+	s := NewServer()
+
+	order := []string{}
+
+	s.AddMiddleware(func(c Context, next func()) {
+		defer func() {
+			if err := recover(); err != nil {
+				order = append(order, "recovered")
+			}
+		}()
+
+		c.Defer(func() { order = append(order, "first") })
+
+		next()
+	})
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Defer(func() { order = append(order, "second") })
+
+		panic("test")
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if len(order) != 3 || order[0] != "recovered" || order[1] != "second" || order[2] != "first" {
+		t.Errorf("Unexpected cleanup order: %#v", order)
+	}
+}
+
+func TestServerContextFactoryError(t *testing.T) {
+	s := NewServer()
+
+	ran := false
+
+	s.AddContextFactory(func(c Context) {
+		c.Response().AddError(errors.New("Unable to open resource"))
+	})
+
+	s.AddMiddleware(func(c Context, next func()) {
+		ran = true
+	})
+
+	w := newMockWriter()
+
+	s.ServeHTTP(w, &http.Request{})
+
+	if ran {
+		t.Error("Middleware unexpectedly ran after a context factory failed")
+	}
+
+	if w.status != 500 {
+		t.Errorf("Expected status 500, got %d instead", w.status)
+	}
+
+	if string(w.written) != `[{"message":"An server error has occurred.","statusCode":500}]` || w.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected response: %s (%s)", w.written, w.header.Get("Content-Type"))
+	}
+}
+
+func TestServerNilContextFactory(t *testing.T) {
+	s := NewServer()
+
+	s.AddContextFactory(func(c Context) {})
+
+	defer func() {
+		if r := recover(); r != "bowtie: context factory #1 is nil" {
+			t.Errorf("Unexpected panic for a nil context factory: %v", r)
+		}
+	}()
+
+	s.AddContextFactory(nil)
+}
+
+func TestServerRespondStopsChain(t *testing.T) {
+	s := NewServer()
+
+	ran := false
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Respond(http.StatusAccepted, map[string]interface{}{"queued": true})
+	})
+
+	s.AddMiddleware(func(c Context, next func()) {
+		ran = true
+	})
+
+	w := newMockWriter()
+
+	s.ServeHTTP(w, &http.Request{})
+
+	if ran {
+		t.Error("Middleware unexpectedly ran after Respond")
+	}
+
+	if w.status != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d instead", http.StatusAccepted, w.status)
+	}
+
+	if string(w.written) != `{"queued":true}` || w.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected response: %s (%s)", w.written, w.header.Get("Content-Type"))
+	}
+}
+
+func TestServerTrustedProxiesClientIP(t *testing.T) {
+	s := NewServer()
+
+	s.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+
+	expect := func(remoteAddr, forwarded, ip string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+
+		if clientIP := s.NewContext(r, newMockWriter()).Request().ClientIP(); clientIP != ip {
+			t.Errorf("Expected client IP %s for %s (%s), got %s instead", ip, remoteAddr, forwarded, clientIP)
+		}
+	}
+
+	expect("203.0.113.5:1234", "", "203.0.113.5")
+	expect("203.0.113.5:1234", "198.51.100.7", "203.0.113.5")
+	expect("10.1.2.3:1234", "198.51.100.7", "198.51.100.7")
+	expect("10.1.2.3:1234", "198.51.100.7, 192.168.1.1", "198.51.100.7")
+	expect("10.1.2.3:1234", "1.2.3.4, 198.51.100.7, 10.0.0.1", "198.51.100.7")
+	expect("192.168.1.2:1234", "198.51.100.7", "192.168.1.2")
+}
+
+func TestServerCommitsPendingStatus(t *testing.T) {
+	s := NewServer()
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().SetStatus(http.StatusNoContent)
+	})
+
+	w := newMockWriter()
+
+	s.ServeHTTP(w, &http.Request{})
+
+	if w.status != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d instead", http.StatusNoContent, w.status)
+	}
+}
+
+func TestServerMiddlewares(t *testing.T) {
+	s := NewServer()
+
+	s.AddNamedMiddleware("logger", testMiddleware)
+	s.AddMiddleware(testMiddleware)
+	s.AddNamedMiddleware("router", testMiddleware)
+
+	info := s.Middlewares()
+
+	if len(info) != 3 {
+		t.Fatalf("Expected 3 middlewares, got %d instead", len(info))
+	}
+
+	for index, name := range []string{"logger", "", "router"} {
+		if info[index].Position != index || info[index].Name != name {
+			t.Errorf("Unexpected middleware info at position %d: %#v", index, info[index])
+		}
+	}
+}
+
+func TestServerRemoteAddr(t *testing.T) {
+	expect := func(s *Server, forwarded, addr string) {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "10.1.2.3:1234"
+		r.Header.Set("X-Forwarded-For", forwarded)
+
+		if remoteAddr := s.NewContext(r, newMockWriter()).RemoteAddr(); remoteAddr != addr {
+			t.Errorf("Expected remote address %s, got %s instead", addr, remoteAddr)
+		}
+	}
+
+	s := NewServer()
+
+	expect(s, "198.51.100.7", "10.1.2.3")
+
+	s = NewServer()
+	s.TrustedProxies = []string{"10.0.0.0/8"}
+
+	expect(s, "198.51.100.7", "198.51.100.7")
+}
+
+func TestServerInvalidTrustedProxies(t *testing.T) {
+	s := NewServer()
+
+	s.TrustedProxies = []string{"10.0.0.0/8", "10.0.0.0/33"}
+
+	if err := s.ListenAndServe("127.0.0.1:0"); err == nil || err.Error() != `bowtie: invalid trusted proxy "10.0.0.0/33"` {
+		t.Errorf("Unexpected error for an invalid trusted proxy: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected an invalid trusted proxy to cause a panic")
+		}
+	}()
+
+	s.NewContext(&http.Request{}, newMockWriter())
+}
+
+func TestServerContextFlags(t *testing.T) {
+	s := NewServer()
+
+	var beta, legacy bool
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.SetFlag("beta", true)
+		c.SetFlag("legacy", true)
+		c.SetFlag("legacy", false)
+	})
+
+	s.AddMiddleware(func(c Context, next func()) {
+		beta = c.Flag("beta")
+		legacy = c.Flag("legacy")
+
+		if c.Flag("unknown") {
+			t.Error("Expected unknown flags to be off")
+		}
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if !beta || legacy {
+		t.Errorf("Unexpected flags: beta=%v, legacy=%v", beta, legacy)
+	}
+}
+
+func TestServerAutoCaptureStacks(t *testing.T) {
+	s := NewServer()
+
+	var errs []Error
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().AddError(NewError(400, "Bad request"))
+		c.Response().AddError(errors.New("Something broke"))
+
+		errs = c.Response().Errors()
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if len(errs[0].StackTrace()) != 0 || len(errs[1].StackTrace()) != 0 {
+		t.Error("Expected no stack traces unless AutoCaptureStacks is enabled")
+	}
+
+	s.AutoCaptureStacks = true
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if len(errs[0].StackTrace()) != 0 {
+		t.Error("Expected no stack trace for a 4xx error")
+	}
+
+	if stack, ok := errs[1].PrivateRepresentation()["stackTrace"].([]StackFrame); !ok || len(stack) == 0 {
+		t.Error("Expected a stack trace for a 5xx error")
+	}
+}
+
+func TestServerOnResponse(t *testing.T) {
+	s := NewServer()
+
+	var status int
+	var size int64
+
+	s.OnResponse(func(c Context) {
+		status = c.Response().Status()
+		size = c.Response().BytesWritten()
+	})
+
+	s.AddMiddleware(func(c Context, next func()) {
+		c.Response().SetStatus(http.StatusCreated)
+		c.Response().WriteString("Hello, world")
+	})
+
+	s.ServeHTTP(newMockWriter(), &http.Request{})
+
+	if status != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d instead", http.StatusCreated, status)
+	}
+
+	if size != 12 {
+		t.Errorf("Expected 12 bytes to be written, got %d instead", size)
+	}
+}
+
+func TestServerRunResetsRunningTime(t *testing.T) {
+	clock := NewFakeClock(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	DefaultClock = clock
+	defer func() { DefaultClock = RealClock }()
+
+	s := NewServer()
+
+	var runningTime time.Duration
+
+	s.AddMiddleware(func(c Context, next func()) {
+		clock.Advance(time.Second)
+
+		runningTime = c.GetRunningTime()
+	})
+
+	c := s.NewContext(&http.Request{}, newMockWriter())
+
+	for _, idle := range []time.Duration{5 * time.Second, time.Minute} {
+		clock.Advance(idle)
+
+		s.Run(c)
+
+		if runningTime != time.Second {
+			t.Errorf("Expected a running time of 1s, got %s instead", runningTime)
+		}
+	}
+}